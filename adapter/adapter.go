@@ -0,0 +1,136 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package adapter makes this library the backend for the logging
+// interfaces of 3rd-party packages. Each adapter maps the package's
+// levels onto the loggers here: debug messages go to a debugger,
+// informational messages are journaled by applog at the TRACE level,
+// and warnings and errors go to the alert logger.
+package adapter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/whamcloud/logging/alert"
+	"github.com/whamcloud/logging/applog"
+	"github.com/whamcloud/logging/debug"
+	"github.com/whamcloud/logging/internal/loggers"
+	"github.com/whamcloud/logging/record"
+)
+
+type (
+	// Backend holds the loggers which adapters route messages to,
+	// along with any name and fields added by the adapter's caller
+	Backend struct {
+		loggers loggers.Set
+
+		name   string
+		fields record.Fields
+	}
+
+	// OptSetter sets backend options
+	OptSetter func(*Backend)
+)
+
+// Debugger sets the debugger which receives debug messages. By
+// default, this is the package-level debugger.
+func Debugger(d *debug.Debugger) OptSetter {
+	return func(b *Backend) {
+		b.loggers.Debug = d
+	}
+}
+
+// AppLogger sets the logger which journals informational messages. By
+// default, this is the standard applog logger.
+func AppLogger(l *applog.AppLogger) OptSetter {
+	return func(b *Backend) {
+		b.loggers.App = l
+	}
+}
+
+// Alert sets the logger which receives warnings and errors. By
+// default, this is the package-level alert logger.
+func Alert(l *alert.Logger) OptSetter {
+	return func(b *Backend) {
+		b.loggers.Alert = l
+	}
+}
+
+// Name sets a name which prefixes every message, e.g. "grpc"
+func Name(name string) OptSetter {
+	return func(b *Backend) {
+		b.name = name
+	}
+}
+
+// Debugger verbosity levels for 3rd-party debug and trace messages
+const (
+	debugVerbosity = debug.DefaultVerbosity
+	traceVerbosity = debugVerbosity + 1
+)
+
+func newBackend(options []OptSetter) Backend {
+	var b Backend
+	for _, option := range options {
+		option(&b)
+	}
+	return b
+}
+
+// named returns a copy of the backend with name appended to its name
+func (b Backend) named(name, sep string) Backend {
+	if b.name != "" {
+		name = b.name + sep + name
+	}
+	b.name = name
+	return b
+}
+
+// with returns a copy of the backend which adds the alternating keys
+// and values to every message
+func (b Backend) with(keysAndValues ...interface{}) Backend {
+	b.fields = b.fields.With(keysAndValues...)
+	return b
+}
+
+func (b *Backend) record(msg string, keysAndValues []interface{}) record.Record {
+	if b.name != "" {
+		msg = b.name + ": " + msg
+	}
+	fields := b.fields
+	if len(keysAndValues) > 0 {
+		fields = fields.With(keysAndValues...)
+	}
+	return record.Record{Message: msg, Fields: fields}
+}
+
+// debugEnabled indicates whether debug messages at the verbosity level
+// could be logged, so that adapters can skip formatting them
+func (b *Backend) debugEnabled(level int) bool {
+	return b.loggers.Debugger().Active(level)
+}
+
+func (b *Backend) debugPC(pc uintptr, level int, msg string, keysAndValues ...interface{}) {
+	b.loggers.Debugger().OutputPC(pc, level, b.record(msg, keysAndValues))
+}
+
+func (b *Backend) infoPC(pc uintptr, msg string, keysAndValues ...interface{}) {
+	b.loggers.AppLogger().OutputPC(pc, applog.TRACE, b.record(msg, keysAndValues))
+}
+
+func (b *Backend) alertPC(pc uintptr, severity record.Severity, msg string, keysAndValues ...interface{}) {
+	r := b.record(msg, keysAndValues)
+	r.Severity = severity
+	b.loggers.AlertLogger().OutputPC(pc, r)
+}
+
+func (b *Backend) fatalPC(pc uintptr, msg string) {
+	b.loggers.AlertLogger().FatalPC(pc, b.record(msg, nil))
+}
+
+// sprintln formats like fmt.Sprintln, without the trailing newline
+func sprintln(v ...interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(v...), "\n")
+}
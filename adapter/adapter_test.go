@@ -0,0 +1,177 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package adapter_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/whamcloud/logging/adapter"
+	"github.com/whamcloud/logging/alert"
+	"github.com/whamcloud/logging/applog"
+	"github.com/whamcloud/logging/debug"
+
+	"github.com/go-logr/logr"
+)
+
+// Copies of the interfaces implemented by the adapters, so that the
+// tests don't depend on the packages which define them
+type (
+	grpcLoggerV2 interface {
+		Info(args ...interface{})
+		Infoln(args ...interface{})
+		Infof(format string, args ...interface{})
+		Warning(args ...interface{})
+		Warningln(args ...interface{})
+		Warningf(format string, args ...interface{})
+		Error(args ...interface{})
+		Errorln(args ...interface{})
+		Errorf(format string, args ...interface{})
+		Fatal(args ...interface{})
+		Fatalln(args ...interface{})
+		Fatalf(format string, args ...interface{})
+		V(l int) bool
+	}
+
+	grpcDepthLoggerV2 interface {
+		InfoDepth(depth int, args ...interface{})
+		WarningDepth(depth int, args ...interface{})
+		ErrorDepth(depth int, args ...interface{})
+		FatalDepth(depth int, args ...interface{})
+	}
+
+	leveledLogger interface {
+		Error(msg string, keysAndValues ...interface{})
+		Info(msg string, keysAndValues ...interface{})
+		Debug(msg string, keysAndValues ...interface{})
+		Warn(msg string, keysAndValues ...interface{})
+	}
+)
+
+var (
+	_ grpcLoggerV2      = (*adapter.GRPCLogger)(nil)
+	_ grpcDepthLoggerV2 = (*adapter.GRPCLogger)(nil)
+	_ leveledLogger     = (*adapter.LeveledLogger)(nil)
+	_ leveledLogger     = (*adapter.HCLogger)(nil)
+)
+
+type buffers struct {
+	debug, journal, alert bytes.Buffer
+	d                     *debug.Debugger
+}
+
+func (b *buffers) options() []adapter.OptSetter {
+	b.d = debug.NewDebugger(&b.debug)
+	return []adapter.OptSetter{
+		adapter.Debugger(b.d),
+		adapter.AppLogger(applog.New(applog.JournalFile(&b.journal), applog.DisplayLevel(applog.SILENT))),
+		adapter.Alert(alert.NewLogger(&b.alert)),
+	}
+}
+
+func lines(buf *bytes.Buffer) []string {
+	lines := strings.Split(buf.String(), "\n")
+	return lines[:len(lines)-1] // Don't want the empty line
+}
+
+func TestGRPCLogger(t *testing.T) {
+	var b buffers
+	g := adapter.NewGRPCLogger(append(b.options(), adapter.Name("grpc"))...)
+
+	if g.V(2) {
+		t.Fatal("V(2) enabled with debugging disabled")
+	}
+	g.Infoln("info", 1)
+	g.Warningf("warning %d", 2)
+	g.ErrorDepth(0, "error", 3)
+
+	if journal := lines(&b.journal); len(journal) != 1 || !strings.HasSuffix(journal[0], "grpc: info 1") {
+		t.Fatalf("unexpected journal output: %q", journal)
+	}
+	alerts := lines(&b.alert)
+	if len(alerts) != 2 || !strings.HasSuffix(alerts[0], "grpc: warning 2") || !strings.HasSuffix(alerts[1], "grpc: error 3") {
+		t.Fatalf("unexpected alert output: %q", alerts)
+	}
+	if !strings.Contains(alerts[1], "adapter/adapter_test.go:") {
+		t.Fatalf("expected the caller's location, got %q", alerts[1])
+	}
+
+	b.d.Enable()
+	b.d.SetVerbosity(2)
+	if !g.V(2) || g.V(3) {
+		t.Fatal("expected V(2) to be the highest enabled level")
+	}
+}
+
+func TestLeveledLogger(t *testing.T) {
+	var b buffers
+	l := adapter.NewLeveledLogger(b.options()...)
+
+	l.Debug("not logged")
+	b.d.Enable()
+	l.Debug("debug", "attempt", 1)
+	l.Info("info", "url", "http://localhost/")
+	l.Error("error", "err", "oops")
+
+	if debugLines := lines(&b.debug); len(debugLines) != 1 || !strings.HasSuffix(debugLines[0], "debug attempt=1") {
+		t.Fatalf("unexpected debug output: %q", debugLines)
+	}
+	if !strings.HasSuffix(b.journal.String(), "info url=http://localhost/\n") {
+		t.Fatalf("unexpected journal output: %q", b.journal.String())
+	}
+	if !strings.HasSuffix(b.alert.String(), "error err=oops\n") {
+		t.Fatalf("unexpected alert output: %q", b.alert.String())
+	}
+}
+
+func TestHCLogger(t *testing.T) {
+	var b buffers
+	h := adapter.NewHCLogger(b.options()...).Named("raft").With("node", "a")
+	b.d.Enable()
+
+	if h.IsTrace() || !h.IsDebug() {
+		t.Fatal("expected only debug to be enabled")
+	}
+	h.Trace("not logged")
+	h.Named("log").Debug("debug", "index", 3)
+	h.Warn("warn")
+
+	if debugLines := lines(&b.debug); len(debugLines) != 1 || !strings.HasSuffix(debugLines[0], "raft.log: debug node=a index=3") {
+		t.Fatalf("unexpected debug output: %q", debugLines)
+	}
+	if !strings.HasSuffix(b.alert.String(), "raft: warn node=a\n") {
+		t.Fatalf("unexpected alert output: %q", b.alert.String())
+	}
+	if h.Name() != "raft" {
+		t.Fatalf("expected name raft, got %s", h.Name())
+	}
+}
+
+func TestLogSink(t *testing.T) {
+	var b buffers
+	l := logr.New(adapter.NewLogSink(b.options()...)).WithName("controller").WithValues("id", 7)
+	b.d.Enable()
+
+	l.Info("info")
+	l.V(1).Info("verbose", "n", 1)
+	l.V(2).Info("not logged")
+	l.Error(errors.New("oops"), "failed")
+
+	if journal := lines(&b.journal); len(journal) != 1 || !strings.HasSuffix(journal[0], "controller: info id=7") {
+		t.Fatalf("unexpected journal output: %q", journal)
+	}
+	debugLines := lines(&b.debug)
+	if len(debugLines) != 1 || !strings.HasSuffix(debugLines[0], "controller: verbose id=7 n=1") {
+		t.Fatalf("unexpected debug output: %q", debugLines)
+	}
+	if !strings.Contains(debugLines[0], "adapter_test.go:") {
+		t.Fatalf("expected the caller's location, got %q", debugLines[0])
+	}
+	if !strings.HasSuffix(b.alert.String(), "controller: failed id=7 error=oops\n") {
+		t.Fatalf("unexpected alert output: %q", b.alert.String())
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package adapter
+
+import (
+	"fmt"
+
+	"github.com/whamcloud/logging/record"
+)
+
+type (
+	// GRPCLogger implements the grpclog.LoggerV2 and DepthLoggerV2
+	// interfaces, e.g. grpclog.SetLoggerV2(adapter.NewGRPCLogger()).
+	// Info messages are journaled, warnings and errors are alerts, and
+	// V(l) reports whether the debugger is enabled at verbosity l.
+	GRPCLogger struct {
+		b Backend
+	}
+)
+
+// NewGRPCLogger returns a new *GRPCLogger
+func NewGRPCLogger(options ...OptSetter) *GRPCLogger {
+	return &GRPCLogger{b: newBackend(options)}
+}
+
+// Info logs to INFO log. Arguments are handled in the manner of fmt.Print.
+func (g *GRPCLogger) Info(args ...interface{}) {
+	g.b.infoPC(record.CallerPC(2), fmt.Sprint(args...))
+}
+
+// Infoln logs to INFO log. Arguments are handled in the manner of fmt.Println.
+func (g *GRPCLogger) Infoln(args ...interface{}) {
+	g.b.infoPC(record.CallerPC(2), sprintln(args...))
+}
+
+// Infof logs to INFO log. Arguments are handled in the manner of fmt.Printf.
+func (g *GRPCLogger) Infof(format string, args ...interface{}) {
+	g.b.infoPC(record.CallerPC(2), fmt.Sprintf(format, args...))
+}
+
+// Warning logs to WARNING log. Arguments are handled in the manner of fmt.Print.
+func (g *GRPCLogger) Warning(args ...interface{}) {
+	g.b.alertPC(record.CallerPC(2), record.SeverityWarning, fmt.Sprint(args...))
+}
+
+// Warningln logs to WARNING log. Arguments are handled in the manner of fmt.Println.
+func (g *GRPCLogger) Warningln(args ...interface{}) {
+	g.b.alertPC(record.CallerPC(2), record.SeverityWarning, sprintln(args...))
+}
+
+// Warningf logs to WARNING log. Arguments are handled in the manner of fmt.Printf.
+func (g *GRPCLogger) Warningf(format string, args ...interface{}) {
+	g.b.alertPC(record.CallerPC(2), record.SeverityWarning, fmt.Sprintf(format, args...))
+}
+
+// Error logs to ERROR log. Arguments are handled in the manner of fmt.Print.
+func (g *GRPCLogger) Error(args ...interface{}) {
+	g.b.alertPC(record.CallerPC(2), record.SeverityError, fmt.Sprint(args...))
+}
+
+// Errorln logs to ERROR log. Arguments are handled in the manner of fmt.Println.
+func (g *GRPCLogger) Errorln(args ...interface{}) {
+	g.b.alertPC(record.CallerPC(2), record.SeverityError, sprintln(args...))
+}
+
+// Errorf logs to ERROR log. Arguments are handled in the manner of fmt.Printf.
+func (g *GRPCLogger) Errorf(format string, args ...interface{}) {
+	g.b.alertPC(record.CallerPC(2), record.SeverityError, fmt.Sprintf(format, args...))
+}
+
+// Fatal logs to the alert log, then exits through the shutdown pipeline.
+// Arguments are handled in the manner of fmt.Print.
+func (g *GRPCLogger) Fatal(args ...interface{}) {
+	g.b.fatalPC(record.CallerPC(2), fmt.Sprint(args...))
+}
+
+// Fatalln logs to the alert log, then exits through the shutdown pipeline.
+// Arguments are handled in the manner of fmt.Println.
+func (g *GRPCLogger) Fatalln(args ...interface{}) {
+	g.b.fatalPC(record.CallerPC(2), sprintln(args...))
+}
+
+// Fatalf logs to the alert log, then exits through the shutdown pipeline.
+// Arguments are handled in the manner of fmt.Printf.
+func (g *GRPCLogger) Fatalf(format string, args ...interface{}) {
+	g.b.fatalPC(record.CallerPC(2), fmt.Sprintf(format, args...))
+}
+
+// V reports whether verbosity level l is at least the requested verbose level.
+func (g *GRPCLogger) V(l int) bool {
+	return l <= 0 || g.b.debugEnabled(l)
+}
+
+// InfoDepth logs to INFO log at the specified depth. Arguments are handled
+// in the manner of fmt.Println.
+func (g *GRPCLogger) InfoDepth(depth int, args ...interface{}) {
+	g.b.infoPC(record.CallerPC(depth+2), sprintln(args...))
+}
+
+// WarningDepth logs to WARNING log at the specified depth. Arguments are
+// handled in the manner of fmt.Println.
+func (g *GRPCLogger) WarningDepth(depth int, args ...interface{}) {
+	g.b.alertPC(record.CallerPC(depth+2), record.SeverityWarning, sprintln(args...))
+}
+
+// ErrorDepth logs to ERROR log at the specified depth. Arguments are
+// handled in the manner of fmt.Println.
+func (g *GRPCLogger) ErrorDepth(depth int, args ...interface{}) {
+	g.b.alertPC(record.CallerPC(depth+2), record.SeverityError, sprintln(args...))
+}
+
+// FatalDepth logs to the alert log at the specified depth, then exits
+// through the shutdown pipeline. Arguments are handled in the manner of
+// fmt.Println.
+func (g *GRPCLogger) FatalDepth(depth int, args ...interface{}) {
+	g.b.fatalPC(record.CallerPC(depth+2), sprintln(args...))
+}
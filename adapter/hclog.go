@@ -0,0 +1,105 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package adapter
+
+import (
+	"github.com/whamcloud/logging/record"
+)
+
+type (
+	// HCLogger implements the leveled subset of the hclog.Logger
+	// interface used by most packages which accept one: Trace, Debug,
+	// Info, Warn and Error with alternating keys and values, the Is*
+	// checks, With and Named. Trace and Debug messages go to the
+	// debugger at verbosity 2 and 1 respectively.
+	HCLogger struct {
+		b Backend
+	}
+)
+
+// NewHCLogger returns a new *HCLogger
+func NewHCLogger(options ...OptSetter) *HCLogger {
+	return &HCLogger{b: newBackend(options)}
+}
+
+// Trace logs the message if debugging is enabled at verbosity 2
+func (h *HCLogger) Trace(msg string, args ...interface{}) {
+	if !h.b.debugEnabled(traceVerbosity) {
+		return
+	}
+	h.b.debugPC(record.CallerPC(2), traceVerbosity, msg, args...)
+}
+
+// Debug logs the message if debugging is enabled
+func (h *HCLogger) Debug(msg string, args ...interface{}) {
+	if !h.b.debugEnabled(debugVerbosity) {
+		return
+	}
+	h.b.debugPC(record.CallerPC(2), debugVerbosity, msg, args...)
+}
+
+// Info records the message in the applog journal
+func (h *HCLogger) Info(msg string, args ...interface{}) {
+	h.b.infoPC(record.CallerPC(2), msg, args...)
+}
+
+// Warn logs an alert at warning severity
+func (h *HCLogger) Warn(msg string, args ...interface{}) {
+	h.b.alertPC(record.CallerPC(2), record.SeverityWarning, msg, args...)
+}
+
+// Error logs an alert at error severity
+func (h *HCLogger) Error(msg string, args ...interface{}) {
+	h.b.alertPC(record.CallerPC(2), record.SeverityError, msg, args...)
+}
+
+// IsTrace indicates whether Trace messages could be logged
+func (h *HCLogger) IsTrace() bool {
+	return h.b.debugEnabled(traceVerbosity)
+}
+
+// IsDebug indicates whether Debug messages could be logged
+func (h *HCLogger) IsDebug() bool {
+	return h.b.debugEnabled(debugVerbosity)
+}
+
+// IsInfo always returns true, as Info messages are always journaled
+func (h *HCLogger) IsInfo() bool {
+	return true
+}
+
+// IsWarn always returns true
+func (h *HCLogger) IsWarn() bool {
+	return true
+}
+
+// IsError always returns true
+func (h *HCLogger) IsError() bool {
+	return true
+}
+
+// With returns a logger which adds the alternating keys and values to
+// every message
+func (h *HCLogger) With(args ...interface{}) *HCLogger {
+	return &HCLogger{b: h.b.with(args...)}
+}
+
+// Named returns a logger whose name has the supplied name appended,
+// separated by a dot
+func (h *HCLogger) Named(name string) *HCLogger {
+	return &HCLogger{b: h.b.named(name, ".")}
+}
+
+// ResetNamed returns a logger with the supplied name
+func (h *HCLogger) ResetNamed(name string) *HCLogger {
+	b := h.b
+	b.name = name
+	return &HCLogger{b: b}
+}
+
+// Name returns the logger's name
+func (h *HCLogger) Name() string {
+	return h.b.name
+}
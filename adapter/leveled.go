@@ -0,0 +1,46 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package adapter
+
+import (
+	"github.com/whamcloud/logging/record"
+)
+
+type (
+	// LeveledLogger implements the retryablehttp.LeveledLogger
+	// interface, e.g. client.Logger = adapter.NewLeveledLogger().
+	// Messages are followed by alternating keys and values.
+	LeveledLogger struct {
+		b Backend
+	}
+)
+
+// NewLeveledLogger returns a new *LeveledLogger
+func NewLeveledLogger(options ...OptSetter) *LeveledLogger {
+	return &LeveledLogger{b: newBackend(options)}
+}
+
+// Error logs an alert at error severity
+func (l *LeveledLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.b.alertPC(record.CallerPC(2), record.SeverityError, msg, keysAndValues...)
+}
+
+// Warn logs an alert at warning severity
+func (l *LeveledLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.b.alertPC(record.CallerPC(2), record.SeverityWarning, msg, keysAndValues...)
+}
+
+// Info records the message in the applog journal
+func (l *LeveledLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.b.infoPC(record.CallerPC(2), msg, keysAndValues...)
+}
+
+// Debug logs the message if debugging is enabled
+func (l *LeveledLogger) Debug(msg string, keysAndValues ...interface{}) {
+	if !l.b.debugEnabled(debugVerbosity) {
+		return
+	}
+	l.b.debugPC(record.CallerPC(2), debugVerbosity, msg, keysAndValues...)
+}
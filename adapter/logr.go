@@ -0,0 +1,74 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package adapter
+
+import (
+	"github.com/whamcloud/logging/record"
+
+	"github.com/go-logr/logr"
+)
+
+type (
+	// LogSink implements logr.LogSink, e.g.
+	// logr.New(adapter.NewLogSink()). V(0) messages are journaled,
+	// higher V levels go to the debugger at that verbosity, and errors
+	// are alerts.
+	LogSink struct {
+		b         Backend
+		callDepth int
+	}
+)
+
+var _ logr.CallDepthLogSink = (*LogSink)(nil)
+
+// NewLogSink returns a new *LogSink
+func NewLogSink(options ...OptSetter) *LogSink {
+	return &LogSink{b: newBackend(options)}
+}
+
+// Init receives the number of frames between the caller and the sink
+func (s *LogSink) Init(info logr.RuntimeInfo) {
+	s.callDepth = info.CallDepth
+}
+
+// Enabled indicates whether messages at the V level could be logged
+func (s *LogSink) Enabled(level int) bool {
+	return level <= 0 || s.b.debugEnabled(level)
+}
+
+// Info journals V(0) messages, and logs higher V levels to the debugger
+func (s *LogSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	pc := record.CallerPC(s.callDepth + 2)
+	if level <= 0 {
+		s.b.infoPC(pc, msg, keysAndValues...)
+		return
+	}
+	s.b.debugPC(pc, level, msg, keysAndValues...)
+}
+
+// Error logs an alert at error severity, with the error as the first
+// field
+func (s *LogSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	keysAndValues = append([]interface{}{"error", err}, keysAndValues...)
+	s.b.alertPC(record.CallerPC(s.callDepth+2), record.SeverityError, msg, keysAndValues...)
+}
+
+// WithValues returns a sink which adds the alternating keys and values
+// to every message
+func (s *LogSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return &LogSink{b: s.b.with(keysAndValues...), callDepth: s.callDepth}
+}
+
+// WithName returns a sink whose name has the supplied name appended,
+// separated by a slash
+func (s *LogSink) WithName(name string) logr.LogSink {
+	return &LogSink{b: s.b.named(name, "/"), callDepth: s.callDepth}
+}
+
+// WithCallDepth returns a sink which skips extra frames when finding
+// the caller
+func (s *LogSink) WithCallDepth(depth int) logr.LogSink {
+	return &LogSink{b: s.b, callDepth: s.callDepth + depth}
+}
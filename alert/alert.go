@@ -5,19 +5,25 @@
 package alert
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"sync/atomic"
 
 	"github.com/whamcloud/logging/external"
+	"github.com/whamcloud/logging/record"
+	"github.com/whamcloud/logging/shutdown"
 )
 
 type (
-	// Logger wraps a *log.Logger with some configuration and
+	// Logger wraps a *record.Logger with some configuration and
 	// convenience methods
 	Logger struct {
-		log *log.Logger
+		log    *record.Logger
+		exit   shutdown.ExitHandler
+		stacks int32
 	}
 )
 
@@ -34,7 +40,7 @@ func init() {
 // NewLogger returns a *Logger
 func NewLogger(out io.Writer) *Logger {
 	return &Logger{
-		log: log.New(out, "ALERT ", logFlags),
+		log: record.NewLogger(out, "alert", record.SeverityWarning, "ALERT ", logFlags),
 	}
 }
 
@@ -48,9 +54,50 @@ func (l *Logger) SetOutput(out io.Writer) {
 	l.log.SetOutput(out)
 }
 
+// SetExitHandler sets the handler called by Fatal, Fatalf and Abort
+// once the shutdown pipeline has run. A nil handler uses the
+// package-wide handler in the shutdown package.
+func (l *Logger) SetExitHandler(handler shutdown.ExitHandler) {
+	l.exit = handler
+}
+
+// SetStacks sets which goroutine stacks are attached to alert records,
+// which by default have none
+func (l *Logger) SetStacks(mode record.StackMode) {
+	atomic.StoreInt32(&l.stacks, int32(mode))
+}
+
+// SetEncoder sets the encoder used to render log records
+func (l *Logger) SetEncoder(e record.Encoder) {
+	l.log.SetEncoder(e)
+}
+
 // Output writes the output for a logging event
 func (l *Logger) Output(skip int, s string) {
-	l.log.Output(skip, s)
+	l.output(skip+1, record.SeverityUnset, s, nil)
+}
+
+func (l *Logger) output(skip int, severity record.Severity, s string, fields record.Fields) {
+	stack := record.Stack(record.StackMode(atomic.LoadInt32(&l.stacks)))
+	l.log.Output(skip, record.Record{Severity: severity, Message: s, Fields: fields, Stack: stack})
+}
+
+// OutputPC writes r as if it had been logged by the callsite at pc,
+// for adapters such as a slog.Handler which have already captured
+// their caller
+func (l *Logger) OutputPC(pc uintptr, r record.Record) error {
+	if r.Stack == "" {
+		r.Stack = record.Stack(record.StackMode(atomic.LoadInt32(&l.stacks)))
+	}
+	return l.log.OutputPC(pc, r)
+}
+
+// FatalPC writes r like OutputPC, then exits through the shutdown
+// pipeline like Fatal
+func (l *Logger) FatalPC(pc uintptr, r record.Record) {
+	r.Severity = record.SeverityCritical
+	l.OutputPC(pc, r)
+	shutdown.Fatal(l.exit, r.Message)
 }
 
 // Warn outputs a log message from the arguments
@@ -63,16 +110,48 @@ func (l *Logger) Warnf(f string, v ...interface{}) {
 	l.Output(3, fmt.Sprintf(f, v...))
 }
 
-// Fatal outputs a log message from the arguments, then exits
+// Fatal outputs a log message from the arguments, then exits through
+// the shutdown pipeline
 func (l *Logger) Fatal(v ...interface{}) {
-	l.Output(3, fmt.Sprint(v...))
-	os.Exit(1)
+	l.fatal(3, fmt.Sprint(v...), nil)
 }
 
 // Fatalf outputs a formatted log message from the arguments, then exits
+// through the shutdown pipeline
 func (l *Logger) Fatalf(f string, v ...interface{}) {
-	l.Output(3, fmt.Sprintf(f, v...))
-	os.Exit(1)
+	l.fatal(3, fmt.Sprintf(f, v...), nil)
+}
+
+// WarnCtx is like Warn, but also emits the request ID, trace IDs and
+// fields attached to the context
+func (l *Logger) WarnCtx(ctx context.Context, v ...interface{}) {
+	l.output(3, record.SeverityUnset, fmt.Sprint(v...), record.ContextFields(ctx))
+}
+
+// WarnfCtx is like Warnf, with the values attached to ctx
+func (l *Logger) WarnfCtx(ctx context.Context, f string, v ...interface{}) {
+	l.output(3, record.SeverityUnset, fmt.Sprintf(f, v...), record.ContextFields(ctx))
+}
+
+// FatalCtx is like Fatal, with the values attached to ctx
+func (l *Logger) FatalCtx(ctx context.Context, v ...interface{}) {
+	l.fatal(3, fmt.Sprint(v...), record.ContextFields(ctx))
+}
+
+// FatalfCtx is like Fatalf, with the values attached to ctx
+func (l *Logger) FatalfCtx(ctx context.Context, f string, v ...interface{}) {
+	l.fatal(3, fmt.Sprintf(f, v...), record.ContextFields(ctx))
+}
+
+func (l *Logger) fatal(skip int, msg string, fields record.Fields) {
+	l.output(skip+1, record.SeverityCritical, msg, fields)
+	shutdown.Fatal(l.exit, msg)
+}
+
+// WriteRecord writes a record which was created elsewhere, e.g. by a
+// debug flight recorder, with the alert prefix and flags
+func (l *Logger) WriteRecord(r *record.Record) error {
+	return l.log.WriteRecord(r)
 }
 
 // Writer returns a new *external.Writer suitable for injection into
@@ -91,6 +170,11 @@ func (l *Logger) Write(data []byte) (int, error) {
 
 // package-level functions follow
 
+// StandardLogger returns the package-level logger
+func StandardLogger() *Logger {
+	return std
+}
+
 // Writer returns a new *external.Writer suitable for injection into
 // 3rd-party logging packages.
 func Writer() *external.Writer {
@@ -102,6 +186,28 @@ func SetOutput(out io.Writer) {
 	std.SetOutput(out)
 }
 
+// SetExitHandler sets the handler called by Fatal, Fatalf and Abort
+// once the shutdown pipeline has run
+func SetExitHandler(handler shutdown.ExitHandler) {
+	std.SetExitHandler(handler)
+}
+
+// SetStacks sets which goroutine stacks are attached to alert records
+func SetStacks(mode record.StackMode) {
+	std.SetStacks(mode)
+}
+
+// SetEncoder sets the encoder used to render log records
+func SetEncoder(e record.Encoder) {
+	std.SetEncoder(e)
+}
+
+// WriteRecord writes a record which was created elsewhere with the
+// alert prefix and flags
+func WriteRecord(r *record.Record) error {
+	return std.WriteRecord(r)
+}
+
 // Warn outputs a log message from the arguments
 func Warn(v ...interface{}) {
 	std.Output(3, fmt.Sprint(v...))
@@ -112,25 +218,49 @@ func Warnf(f string, v ...interface{}) {
 	std.Output(3, fmt.Sprintf(f, v...))
 }
 
-// Fatal outputs a log message from the arguments, then exits
+// Fatal outputs a log message from the arguments, then exits through
+// the shutdown pipeline
 func Fatal(v ...interface{}) {
-	std.Output(3, fmt.Sprint(v...))
-	os.Exit(1)
+	std.fatal(3, fmt.Sprint(v...), nil)
 }
 
 // Fatalf outputs a formatted log message from the arguments, then exits
+// through the shutdown pipeline
 func Fatalf(f string, v ...interface{}) {
-	std.Output(3, fmt.Sprintf(f, v...))
-	os.Exit(1)
+	std.fatal(3, fmt.Sprintf(f, v...), nil)
+}
+
+// WarnCtx calls WarnCtx on the standard logger
+func WarnCtx(ctx context.Context, v ...interface{}) {
+	std.output(3, record.SeverityUnset, fmt.Sprint(v...), record.ContextFields(ctx))
+}
+
+// WarnfCtx calls WarnfCtx on the standard logger
+func WarnfCtx(ctx context.Context, f string, v ...interface{}) {
+	std.output(3, record.SeverityUnset, fmt.Sprintf(f, v...), record.ContextFields(ctx))
+}
+
+// FatalCtx calls FatalCtx on the standard logger
+func FatalCtx(ctx context.Context, v ...interface{}) {
+	std.fatal(3, fmt.Sprint(v...), record.ContextFields(ctx))
+}
+
+// FatalfCtx calls FatalfCtx on the standard logger
+func FatalfCtx(ctx context.Context, f string, v ...interface{}) {
+	std.fatal(3, fmt.Sprintf(f, v...), record.ContextFields(ctx))
 }
 
-// Abort prints error trace and exits
+// Abort prints error trace and exits through the shutdown pipeline
 func Abort(err error) {
 	// We don't need to see where the abort was called, so we remove
-	// this flag before logging and exiting.
+	// this flag while logging, restoring the caller's flags afterwards
+	// in case the exit handler returns control to them.
+	flags := std.log.Flags()
 	std.SetFlags(logFlags &^ log.Llongfile)
 	msg := fmt.Sprintf("%+v", err)
 
-	std.Output(3, "Aborting program execution due to error(s):\n"+msg)
-	os.Exit(1)
+	msg = "Aborting program execution due to error(s):\n" + msg
+	std.output(3, record.SeverityCritical, msg, nil)
+	std.SetFlags(flags)
+	shutdown.Fatal(std.exit, msg)
 }
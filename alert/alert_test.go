@@ -6,11 +6,13 @@ package alert_test
 
 import (
 	"bytes"
+	"context"
 	"log"
 	"strings"
 	"testing"
 
 	"github.com/whamcloud/logging/alert"
+	"github.com/whamcloud/logging/record"
 )
 
 var testInputs = map[int]string{
@@ -81,3 +83,55 @@ func TestWriterWithLogger(t *testing.T) {
 		t.Fatalf("prefixes wrong: %s", lines[1])
 	}
 }
+
+func TestExitHandler(t *testing.T) {
+	var buf bytes.Buffer
+	a := alert.NewLogger(&buf)
+
+	var code int
+	var msg string
+	a.SetExitHandler(func(c int, m string) {
+		code, msg = c, m
+	})
+	a.Fatal(testInputs[0])
+
+	if code != 1 || msg != testInputs[0] {
+		t.Fatalf("exit handler got %d, %q", code, msg)
+	}
+	if !strings.HasSuffix(buf.String(), testInputs[0]+"\n") {
+		t.Fatalf("message was not logged: %q", buf.String())
+	}
+}
+
+func TestStacks(t *testing.T) {
+	var buf bytes.Buffer
+	a := alert.NewLogger(&buf)
+
+	a.Warn(testInputs[0])
+	a.SetStacks(record.CurrentStack)
+	a.Warn(testInputs[1])
+
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) < 4 || !strings.HasSuffix(lines[0], testInputs[0]) || !strings.HasSuffix(lines[1], testInputs[1]) {
+		t.Fatalf("unexpected output: %q", lines)
+	}
+	if !strings.HasPrefix(lines[2], "goroutine ") || !strings.Contains(lines[3], "alert_test.TestStacks (alert/alert_test.go:") {
+		t.Fatalf("expected a trimmed stack after the alert: %q", lines[2:])
+	}
+}
+
+func TestWarnCtx(t *testing.T) {
+	var buf bytes.Buffer
+	a := alert.NewLogger(&buf)
+
+	ctx := record.WithTrace(context.Background(), "t1", "s1")
+	a.WarnfCtx(ctx, "%s", testInputs[0])
+
+	expected := testInputs[0] + " trace_id=t1 span_id=s1\n"
+	if !strings.HasSuffix(buf.String(), expected) {
+		t.Fatalf("expected %q, got %q", expected, buf.String())
+	}
+	if !strings.Contains(buf.String(), "alert_test.go:") {
+		t.Fatalf("expected the caller's location, got %q", buf.String())
+	}
+}
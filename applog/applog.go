@@ -5,16 +5,22 @@
 package applog
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/whamcloud/logging"
+	"github.com/whamcloud/logging/alert"
 	"github.com/whamcloud/logging/debug"
+	"github.com/whamcloud/logging/external"
+	"github.com/whamcloud/logging/record"
+	"github.com/whamcloud/logging/shutdown"
 
 	"github.com/briandowns/spinner"
 )
@@ -26,6 +32,11 @@ var (
 
 func init() {
 	std = New()
+
+	// Don't leave the spinner running if something else exits the program
+	shutdown.RegisterHook(func() {
+		std.spinner.Stop()
+	})
 }
 
 type displayLevel int
@@ -49,6 +60,30 @@ func (d displayLevel) String() string {
 	}
 }
 
+// ParseLevel returns the display level with the given name, e.g. "TRACE"
+func ParseLevel(name string) (displayLevel, error) {
+	for d := DEBUG; d <= SILENT; d++ {
+		if strings.EqualFold(name, d.String()) {
+			return d, nil
+		}
+	}
+	return USER, fmt.Errorf("unknown display level: %s", name)
+}
+
+// severity maps the display level onto a record severity for the journal
+func (d displayLevel) severity() record.Severity {
+	switch d {
+	case DEBUG, TRACE:
+		return record.SeverityDebug
+	case WARN:
+		return record.SeverityWarning
+	case FAIL:
+		return record.SeverityError
+	default:
+		return record.SeverityInfo
+	}
+}
+
 const (
 	// DEBUG shows all
 	DEBUG displayLevel = iota
@@ -67,22 +102,85 @@ const (
 // LoggedWriter implements io.Writer and is used to redirect logging from
 // 3rd-party libraries to this library.
 type LoggedWriter struct {
-	level  displayLevel
-	prefix string
-	logger *AppLogger
+	level      displayLevel
+	prefix     string
+	logger     *AppLogger
+	alert      *alert.Logger
+	classifier *external.Classifier
+	lines      *external.LineBuffer
 }
 
-// Write logs the data at the specified loglevel
+// Write logs the data at the specified loglevel, or at the level
+// detected by the writer's classifier
 func (w *LoggedWriter) Write(data []byte) (int, error) {
-	msg := string(data)
-	if len(w.prefix) > 0 {
-		msg = fmt.Sprintf("%s %s", w.prefix, data)
+	pc := record.CallerPC(3)
+	if w.lines == nil {
+		w.logLine(pc, string(data))
+		return len(data), nil
 	}
-	w.logger.logAt(w.level, msg)
 
+	w.lines.LockEmit()
+	defer w.lines.UnlockEmit()
+	for _, line := range w.lines.Lines(data) {
+		w.logLine(pc, line)
+	}
 	return len(data), nil
 }
 
+// Close logs any partial line held by a line-buffered writer
+func (w *LoggedWriter) Close() error {
+	if w.lines == nil {
+		return nil
+	}
+	w.lines.LockEmit()
+	defer w.lines.UnlockEmit()
+	if line := w.lines.Flush(); line != "" {
+		w.logLine(0, line)
+	}
+	return nil
+}
+
+// logLine logs a line at the writer's level, or at its classified level.
+// pc is the caller of Write, or zero for a partial line which is logged
+// by Close or after the timeout, and has no meaningful caller.
+func (w *LoggedWriter) logLine(pc uintptr, line string) {
+	msg := line
+	if len(w.prefix) > 0 {
+		msg = fmt.Sprintf("%s %s", w.prefix, line)
+	}
+
+	level := w.level
+	if w.classifier != nil {
+		switch w.classifier.Classify(line) {
+		case external.LevelTrace, external.LevelDebug:
+			level = DEBUG
+		case external.LevelInfo:
+			level = TRACE
+		case external.LevelWarn:
+			level = WARN
+		case external.LevelError:
+			w.logAlert(pc, record.SeverityError, msg)
+			return
+		case external.LevelFatal:
+			w.logAlert(pc, record.SeverityCritical, msg)
+			return
+		}
+	}
+	w.logger.logAt(level, msg)
+}
+
+// logAlert journals an error line and sends it to the alert logger
+func (w *LoggedWriter) logAlert(pc uintptr, severity record.Severity, msg string) {
+	a := w.alert
+	if a == nil {
+		a = alert.StandardLogger()
+	}
+
+	w.logger.setLastEntry(msg)
+	w.logger.journal.OutputPC(pc, record.Record{Level: FAIL.String(), Severity: severity, Message: msg})
+	a.OutputPC(pc, record.Record{Severity: severity, Message: msg})
+}
+
 // Prefix optionally sets the LoggedWriter prefix
 func (w *LoggedWriter) Prefix(prefix string) *LoggedWriter {
 	w.prefix = prefix
@@ -95,28 +193,77 @@ func (w *LoggedWriter) Level(level displayLevel) *LoggedWriter {
 	return w
 }
 
+// Lines optionally buffers the data written until it forms complete
+// lines, which are logged one at a time. Lines longer than maxLen are
+// split, and a partial line is logged after timeout or by Close. A
+// zero maxLen or timeout disables the limit.
+func (w *LoggedWriter) Lines(maxLen int, timeout time.Duration) *LoggedWriter {
+	w.lines = external.NewLineBuffer(maxLen, timeout, func(line string) {
+		w.logLine(0, line)
+	})
+	return w
+}
+
+// Alert optionally sets the alert logger which receives the error lines
+// detected by a Classifier, instead of the standard alert logger
+func (w *LoggedWriter) Alert(l *alert.Logger) *LoggedWriter {
+	w.alert = l
+	return w
+}
+
+// Classifier optionally detects the level of each line written, e.g.
+// "[WARN]" or "level=debug". Debug and trace lines are logged at DEBUG,
+// info lines at TRACE and warnings at WARN, while errors are journaled
+// and sent to the alert logger rather than failing. Lines without a
+// detected level are logged at the writer's level.
+func (w *LoggedWriter) Classifier(c *external.Classifier) *LoggedWriter {
+	w.classifier = c
+	return w
+}
+
 // OptSetter sets logger options
 type OptSetter func(*AppLogger)
 
-// JournalFile configures the logger's journaler
-func JournalFile(w interface{}) OptSetter {
-	writer, err := logging.CreateWriter(w)
+// JournalFile configures the logger's journaler. Options such as
+// logging.Rotate() are passed to logging.CreateWriter, and file paths
+// are opened with logging.Reopenable() so logging.Reopen() finds them.
+func JournalFile(w interface{}, options ...logging.WriterOpt) OptSetter {
+	options = append([]logging.WriterOpt{logging.Reopenable()}, options...)
+	writer, err := logging.CreateWriter(w, options...)
 	if err != nil {
 		panic(fmt.Errorf("Failed to create writer from %v: %s", w, err))
 	}
 
 	return func(l *AppLogger) {
-		l.journal = log.New(writer, "", log.LstdFlags)
+		l.journal.SetOutput(writer)
+	}
+}
+
+// JournalEncoder sets the encoder used to render journal entries
+func JournalEncoder(e record.Encoder) OptSetter {
+	return func(l *AppLogger) {
+		l.journal.SetEncoder(e)
 	}
 }
 
 // DisplayLevel sets the logger's display level
 func DisplayLevel(d displayLevel) OptSetter {
 	return func(l *AppLogger) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
 		l.Level = d
 	}
 }
 
+// ExitHandler sets the handler called by Fail once the shutdown
+// pipeline has run. A nil handler uses the package-wide handler in
+// the shutdown package.
+func ExitHandler(handler shutdown.ExitHandler) OptSetter {
+	return func(l *AppLogger) {
+		l.exit = handler
+	}
+}
+
 // New returns a new AppLogger
 func New(options ...OptSetter) *AppLogger {
 	logger := &AppLogger{
@@ -124,7 +271,7 @@ func New(options ...OptSetter) *AppLogger {
 		out:     os.Stdout,
 		err:     os.Stderr,
 		Level:   USER,
-		journal: log.New(ioutil.Discard, "", log.LstdFlags),
+		journal: record.NewLogger(ioutil.Discard, "applog", record.SeverityInfo, "", log.LstdFlags),
 	}
 
 	for _, option := range options {
@@ -145,7 +292,8 @@ type AppLogger struct {
 	err         io.Writer
 	lastEntry   string
 	currentTask string
-	journal     *log.Logger
+	journal     *record.Logger
+	exit        shutdown.ExitHandler
 }
 
 func (l *AppLogger) logAt(level displayLevel, msg string) {
@@ -181,8 +329,20 @@ func (l *AppLogger) DisplayLevel(level displayLevel) {
 }
 
 // JournalFile configures the logger's journaler
-func (l *AppLogger) JournalFile(w interface{}) {
-	JournalFile(w)(l)
+func (l *AppLogger) JournalFile(w interface{}, options ...logging.WriterOpt) {
+	JournalFile(w, options...)(l)
+}
+
+// ExitHandler sets the handler called by Fail
+func (l *AppLogger) ExitHandler(handler shutdown.ExitHandler) {
+	ExitHandler(handler)(l)
+}
+
+// level returns the display level, which may be changed at runtime
+func (l *AppLogger) level() displayLevel {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.Level
 }
 
 func (l *AppLogger) setLastEntry(entry string) {
@@ -197,7 +357,7 @@ func (l *AppLogger) getLastEntry() string {
 	return l.lastEntry
 }
 
-func (l *AppLogger) recordEntry(level displayLevel, v ...interface{}) {
+func (l *AppLogger) recordEntry(level displayLevel, fields record.Fields, v ...interface{}) {
 	if len(v) == 0 {
 		return
 	}
@@ -216,32 +376,89 @@ func (l *AppLogger) recordEntry(level displayLevel, v ...interface{}) {
 	default:
 		l.setLastEntry(fmt.Sprintf("unknown type in recordEntry: %s", v))
 	}
-	l.journal.Printf("%s: %s", level, l.getLastEntry())
+	l.journal.Output(3, record.Record{
+		Level:    level.String(),
+		Severity: level.severity(),
+		Message:  l.getLastEntry(),
+		Fields:   fields,
+	})
+}
+
+// OutputPC records r in the journal at the given level as if it had
+// been logged by the callsite at pc, and displays its message if the
+// display level allows. Unlike Fail, it never exits.
+func (l *AppLogger) OutputPC(pc uintptr, level displayLevel, r record.Record) {
+	if level == SILENT {
+		return
+	}
+	l.setLastEntry(r.Message)
+	r.Level, r.Severity = level.String(), level.severity()
+	l.journal.OutputPC(pc, r)
+
+	if l.level() > level {
+		return
+	}
+	switch level {
+	case DEBUG, TRACE:
+		fmt.Fprintf(l.out, "%s: %s\n", level, r.Message)
+	case USER:
+		fmt.Fprintln(l.out, r.Message)
+	default:
+		fmt.Fprintf(l.err, "%s: %s\n", level, r.Message)
+	}
 }
 
 // Debug logs the entry and prints to stdout if level <= DEBUG
 func (l *AppLogger) Debug(v ...interface{}) {
-	l.recordEntry(DEBUG, v...)
+	l.debug(nil, v...)
+}
+
+// DebugCtx is like Debug, but also records the request ID, trace IDs
+// and fields attached to the context in the journal
+func (l *AppLogger) DebugCtx(ctx context.Context, v ...interface{}) {
+	l.debug(record.ContextFields(ctx), v...)
+}
+
+func (l *AppLogger) debug(fields record.Fields, v ...interface{}) {
+	l.recordEntry(DEBUG, fields, v...)
 
-	if l.Level <= DEBUG {
+	if l.level() <= DEBUG {
 		fmt.Fprintf(l.out, "%s: %s\n", DEBUG, l.getLastEntry())
 	}
 }
 
 // Trace logs the entry and prints to stdout if level <= TRACE
 func (l *AppLogger) Trace(v ...interface{}) {
-	l.recordEntry(TRACE, v...)
+	l.trace(nil, v...)
+}
 
-	if l.Level <= TRACE {
+// TraceCtx is like Trace, with the values attached to ctx
+func (l *AppLogger) TraceCtx(ctx context.Context, v ...interface{}) {
+	l.trace(record.ContextFields(ctx), v...)
+}
+
+func (l *AppLogger) trace(fields record.Fields, v ...interface{}) {
+	l.recordEntry(TRACE, fields, v...)
+
+	if l.level() <= TRACE {
 		fmt.Fprintf(l.out, "%s: %s\n", TRACE, l.getLastEntry())
 	}
 }
 
 // User logs the entry and prints to stdout if level <= USER
 func (l *AppLogger) User(v ...interface{}) {
-	l.recordEntry(USER, v...)
+	l.user(nil, v...)
+}
+
+// UserCtx is like User, with the values attached to ctx
+func (l *AppLogger) UserCtx(ctx context.Context, v ...interface{}) {
+	l.user(record.ContextFields(ctx), v...)
+}
 
-	if l.Level <= USER {
+func (l *AppLogger) user(fields record.Fields, v ...interface{}) {
+	l.recordEntry(USER, fields, v...)
+
+	if l.level() <= USER {
 		fmt.Fprintln(l.out, l.getLastEntry())
 	}
 }
@@ -254,9 +471,9 @@ func (l *AppLogger) StartTask(v ...interface{}) {
 		l.CompleteTask()
 	}
 
-	l.recordEntry(USER, v...)
+	l.recordEntry(USER, nil, v...)
 
-	if l.Level == USER {
+	if l.level() == USER {
 		l.currentTask = l.getLastEntry()
 		// Don't fill log files with tons of spinner spam!
 		if !WriterIsTerminal(l.out) {
@@ -273,19 +490,19 @@ func (l *AppLogger) CompleteTask(v ...interface{}) {
 	l.spinner.Stop()
 
 	if len(v) == 0 {
-		l.recordEntry(USER, l.currentTask+taskSuffix+"Done.")
+		l.recordEntry(USER, nil, l.currentTask+taskSuffix+"Done.")
 	} else {
 		if fmtStr, ok := v[0].(string); ok {
 			var newArgs []interface{}
 			newArgs = append(newArgs, l.currentTask+taskSuffix+fmtStr)
 			newArgs = append(newArgs, v[1:]...)
-			l.recordEntry(USER, newArgs...)
+			l.recordEntry(USER, nil, newArgs...)
 		} else {
-			l.recordEntry(USER, v...)
+			l.recordEntry(USER, nil, v...)
 		}
 	}
 
-	if l.currentTask != "" && l.Level == USER {
+	if l.currentTask != "" && l.level() == USER {
 		fmt.Fprintln(l.out, l.getLastEntry())
 		l.currentTask = ""
 	}
@@ -293,25 +510,44 @@ func (l *AppLogger) CompleteTask(v ...interface{}) {
 
 // Warn logs the entry and prints to stderr if level <= WARN
 func (l *AppLogger) Warn(v ...interface{}) {
-	l.recordEntry(WARN, v...)
+	l.warn(nil, v...)
+}
+
+// WarnCtx is like Warn, with the values attached to ctx
+func (l *AppLogger) WarnCtx(ctx context.Context, v ...interface{}) {
+	l.warn(record.ContextFields(ctx), v...)
+}
+
+func (l *AppLogger) warn(fields record.Fields, v ...interface{}) {
+	l.recordEntry(WARN, fields, v...)
 
 	l.spinner.Stop()
 	l.currentTask = ""
-	if l.Level <= WARN {
+	if l.level() <= WARN {
 		fmt.Fprintf(l.err, "%s: %s", WARN, l.getLastEntry())
 	}
 }
 
-// Fail logs the entry and prints to stderr if level <= FAIL
+// Fail logs the entry and prints to stderr if level <= FAIL, then exits
+// through the shutdown pipeline
 func (l *AppLogger) Fail(v ...interface{}) {
-	l.recordEntry(FAIL, v...)
+	l.fail(nil, v...)
+}
+
+// FailCtx is like Fail, with the values attached to ctx
+func (l *AppLogger) FailCtx(ctx context.Context, v ...interface{}) {
+	l.fail(record.ContextFields(ctx), v...)
+}
+
+func (l *AppLogger) fail(fields record.Fields, v ...interface{}) {
+	l.recordEntry(FAIL, fields, v...)
 
 	l.spinner.Stop()
 	l.currentTask = ""
-	if l.Level <= FAIL {
+	if l.level() <= FAIL {
 		fmt.Fprintln(l.err, l.getLastEntry())
 	}
-	os.Exit(1)
+	shutdown.Fatal(l.exit, l.getLastEntry())
 }
 
 // StandardLogger returns the standard logger configured by the library
@@ -325,8 +561,19 @@ func SetStandard(l *AppLogger) {
 }
 
 // SetJournal sets the standard logger's journal writer
-func SetJournal(w interface{}) {
-	JournalFile(w)(std)
+func SetJournal(w interface{}, options ...logging.WriterOpt) {
+	JournalFile(w, options...)(std)
+}
+
+// SetExitHandler sets the handler called by the standard logger's Fail
+func SetExitHandler(handler shutdown.ExitHandler) {
+	ExitHandler(handler)(std)
+}
+
+// SetJournalEncoder sets the encoder used to render the standard
+// logger's journal entries
+func SetJournalEncoder(e record.Encoder) {
+	JournalEncoder(e)(std)
 }
 
 // SetLevel sets the standard logger's display level
@@ -340,6 +587,11 @@ func SetLevel(d displayLevel) {
 	}
 }
 
+// CurrentLevel returns the standard logger's display level
+func CurrentLevel() displayLevel {
+	return std.level()
+}
+
 // Debug logs the entry and prints to stdout if level <= DEBUG
 func Debug(v ...interface{}) {
 	std.Debug(v...)
@@ -360,11 +612,37 @@ func Warn(v ...interface{}) {
 	std.Warn(v...)
 }
 
-// Fail logs the entry and prints to stderr if level <= FAIL
+// Fail logs the entry and prints to stderr if level <= FAIL, then exits
+// through the shutdown pipeline
 func Fail(v ...interface{}) {
 	std.Fail(v...)
 }
 
+// DebugCtx calls DebugCtx on the standard logger
+func DebugCtx(ctx context.Context, v ...interface{}) {
+	std.DebugCtx(ctx, v...)
+}
+
+// TraceCtx calls TraceCtx on the standard logger
+func TraceCtx(ctx context.Context, v ...interface{}) {
+	std.TraceCtx(ctx, v...)
+}
+
+// UserCtx calls UserCtx on the standard logger
+func UserCtx(ctx context.Context, v ...interface{}) {
+	std.UserCtx(ctx, v...)
+}
+
+// WarnCtx calls WarnCtx on the standard logger
+func WarnCtx(ctx context.Context, v ...interface{}) {
+	std.WarnCtx(ctx, v...)
+}
+
+// FailCtx calls FailCtx on the standard logger
+func FailCtx(ctx context.Context, v ...interface{}) {
+	std.FailCtx(ctx, v...)
+}
+
 // StartTask logs the entry at USER level and displays a spinner
 // for long-running tasks
 func StartTask(v ...interface{}) {
@@ -0,0 +1,263 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/whamcloud/logging/record"
+	"github.com/whamcloud/logging/shutdown"
+)
+
+// OverflowPolicy determines what an AsyncWriter does when its queue is full
+type OverflowPolicy int
+
+const (
+	// Block waits for space in the queue
+	Block OverflowPolicy = iota
+	// DropNewest discards the write which found the queue full
+	DropNewest
+	// DropOldest discards the oldest queued write to make room
+	DropOldest
+)
+
+// DefaultAsyncQueueSize is the queue size used when none is given
+const DefaultAsyncQueueSize = 1024
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case Block:
+		return "block"
+	case DropNewest:
+		return "drop-newest"
+	case DropOldest:
+		return "drop-oldest"
+	default:
+		return fmt.Sprintf("Unknown policy: %d", int(p))
+	}
+}
+
+// ParseOverflowPolicy returns the OverflowPolicy for the given name
+// ("block", "drop-newest" or "drop-oldest")
+func ParseOverflowPolicy(name string) (OverflowPolicy, error) {
+	for _, p := range []OverflowPolicy{Block, DropNewest, DropOldest} {
+		if strings.EqualFold(name, p.String()) {
+			return p, nil
+		}
+	}
+	return Block, fmt.Errorf("unknown overflow policy: %s", name)
+}
+
+type (
+	// AsyncWriter queues writes in a bounded ring buffer and writes them
+	// to the underlying writer on a background goroutine, so that slow
+	// sinks do not stall the caller. Records from the audit stream are
+	// never dropped: they block when the queue is full, and DropOldest
+	// evicts the oldest other write, or waits if there is none. Queued
+	// writes are drained by the shutdown pipeline before exit.
+	AsyncWriter struct {
+		out    io.Writer
+		policy OverflowPolicy
+
+		mu      sync.Mutex
+		cond    *sync.Cond
+		queue   []asyncEntry
+		head    int
+		count   int
+		busy    bool
+		closed  bool
+		done    chan struct{}
+		dropped uint64
+	}
+
+	asyncEntry struct {
+		data  []byte
+		rec   *record.Record
+		audit bool
+	}
+)
+
+// NewAsyncWriter returns an *AsyncWriter which queues up to size writes
+// for out, handling overflow according to policy.
+func NewAsyncWriter(out io.Writer, size int, policy OverflowPolicy) *AsyncWriter {
+	if size <= 0 {
+		size = DefaultAsyncQueueSize
+	}
+	w := &AsyncWriter{
+		out:    out,
+		policy: policy,
+		queue:  make([]asyncEntry, size),
+		done:   make(chan struct{}),
+	}
+	w.cond = sync.NewCond(&w.mu)
+
+	go w.run()
+	shutdown.Track(w)
+
+	return w
+}
+
+// Dropped returns the number of writes discarded due to overflow
+func (w *AsyncWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// enqueue adds an entry to the queue, applying the overflow policy
+func (w *AsyncWriter) enqueue(e asyncEntry, policy OverflowPolicy) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for !w.closed && w.count == len(w.queue) {
+		switch policy {
+		case DropNewest:
+			atomic.AddUint64(&w.dropped, 1)
+			return nil
+		case DropOldest:
+			if !w.dropOldest() {
+				// Only audit records are queued, so wait for space
+				w.cond.Wait()
+			}
+		default:
+			w.cond.Wait()
+		}
+	}
+	if w.closed {
+		return os.ErrClosed
+	}
+
+	w.queue[(w.head+w.count)%len(w.queue)] = e
+	w.count++
+	w.cond.Broadcast()
+
+	return nil
+}
+
+// dropOldest discards the oldest queued entry which isn't an audit
+// record, and must be called with the lock held
+func (w *AsyncWriter) dropOldest() bool {
+	size := len(w.queue)
+	for i := 0; i < w.count; i++ {
+		if w.queue[(w.head+i)%size].audit {
+			continue
+		}
+		// Move the audit records ahead of it up into its slot
+		for j := i; j > 0; j-- {
+			w.queue[(w.head+j)%size] = w.queue[(w.head+j-1)%size]
+		}
+		w.queue[w.head] = asyncEntry{}
+		w.head = (w.head + 1) % size
+		w.count--
+		atomic.AddUint64(&w.dropped, 1)
+		return true
+	}
+	return false
+}
+
+func (w *AsyncWriter) run() {
+	defer close(w.done)
+
+	for {
+		w.mu.Lock()
+		for w.count == 0 && !w.closed {
+			w.cond.Wait()
+		}
+		if w.count == 0 && w.closed {
+			w.mu.Unlock()
+			return
+		}
+		e := w.queue[w.head]
+		w.queue[w.head] = asyncEntry{}
+		w.head = (w.head + 1) % len(w.queue)
+		w.count--
+		w.busy = true
+		w.cond.Broadcast()
+		w.mu.Unlock()
+
+		if e.rec != nil {
+			w.out.(record.RecordWriter).WriteRecord(e.rec)
+		} else {
+			w.out.Write(e.data)
+		}
+
+		w.mu.Lock()
+		w.busy = false
+		w.cond.Broadcast()
+		w.mu.Unlock()
+	}
+}
+
+// Write implements io.Writer. The data is copied and queued, so the
+// error only reflects whether the writer has been closed.
+func (w *AsyncWriter) Write(data []byte) (int, error) {
+	buf := make([]byte, len(data))
+	copy(buf, data)
+
+	if err := w.enqueue(asyncEntry{data: buf}, w.policy); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// WriteRecord implements record.RecordWriter. If the underlying writer
+// accepts records, a copy of the record is queued; otherwise the encoded
+// line is queued.
+func (w *AsyncWriter) WriteRecord(r *record.Record) error {
+	policy := w.policy
+	if r.Stream == "audit" {
+		policy = Block
+	}
+
+	e := asyncEntry{audit: r.Stream == "audit"}
+	if _, ok := w.out.(record.RecordWriter); ok {
+		rec := *r
+		rec.Encoded = append([]byte(nil), r.Encoded...)
+		e.rec = &rec
+	} else {
+		e.data = append([]byte(nil), r.Encoded...)
+	}
+
+	return w.enqueue(e, policy)
+}
+
+// Flush waits until every queued write has been written
+func (w *AsyncWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for w.count > 0 || w.busy {
+		w.cond.Wait()
+	}
+	return nil
+}
+
+// Close stops accepting writes, drains the queue and closes the
+// underlying writer if it is an io.Closer (other than stdout/stderr).
+func (w *AsyncWriter) Close() error {
+	shutdown.Untrack(w)
+
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return os.ErrClosed
+	}
+	w.closed = true
+	w.cond.Broadcast()
+	w.mu.Unlock()
+
+	<-w.done
+
+	if w.out == os.Stdout || w.out == os.Stderr {
+		return nil
+	}
+	if c, ok := w.out.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
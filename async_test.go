@@ -0,0 +1,139 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package logging_test
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/whamcloud/logging"
+	"github.com/whamcloud/logging/audit"
+)
+
+// gatedWriter blocks writes until the gate is opened, signalling on
+// entered when a write starts
+type gatedWriter struct {
+	gate    chan struct{}
+	entered chan struct{}
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func newGatedWriter() *gatedWriter {
+	return &gatedWriter{gate: make(chan struct{}), entered: make(chan struct{}, 1)}
+}
+
+func (w *gatedWriter) Write(data []byte) (int, error) {
+	select {
+	case w.entered <- struct{}{}:
+	default:
+	}
+	<-w.gate
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(data)
+}
+
+func (w *gatedWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func TestAsyncWriterDropNewest(t *testing.T) {
+	out := newGatedWriter()
+	w := logging.NewAsyncWriter(out, 2, logging.DropNewest)
+
+	// Writes queue up behind the blocked writer until the queue is
+	// full, after which they are dropped.
+	attempts := uint64(1)
+	w.Write([]byte("1\n"))
+	for w.Dropped() == 0 {
+		w.Write([]byte("x\n"))
+		attempts++
+	}
+	close(out.gate)
+	w.Close()
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if lines[0] != "1" {
+		t.Fatalf("expected the first write to survive, got %q", lines)
+	}
+	if uint64(len(lines))+w.Dropped() != attempts {
+		t.Fatalf("writes unaccounted for: %d attempted, %d written, %d dropped", attempts, len(lines), w.Dropped())
+	}
+}
+
+func TestAsyncWriterDropOldest(t *testing.T) {
+	out := newGatedWriter()
+	w := logging.NewAsyncWriter(out, 2, logging.DropOldest)
+
+	for _, line := range []string{"1\n", "2\n", "3\n", "4\n", "5\n"} {
+		w.Write([]byte(line))
+	}
+	close(out.gate)
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Whatever else was dropped, the newest writes must have survived
+	if !strings.HasSuffix(out.String(), "4\n5\n") || w.Dropped() == 0 {
+		t.Fatalf("unexpected output %q with %d dropped", out.String(), w.Dropped())
+	}
+	w.Close()
+}
+
+func TestAsyncWriterAuditBlocks(t *testing.T) {
+	out := newGatedWriter()
+	w := logging.NewAsyncWriter(out, 1, logging.DropNewest)
+	l := audit.NewLogger(w)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			l.Log("audit record")
+		}
+		close(done)
+	}()
+	close(out.gate)
+	<-done
+	w.Close()
+
+	if n := strings.Count(out.String(), "audit record\n"); n != 5 || w.Dropped() != 0 {
+		t.Fatalf("expected 5 audit records and none dropped, got %d (%d dropped)", n, w.Dropped())
+	}
+}
+
+func TestAsyncWriterDropOldestKeepsAudit(t *testing.T) {
+	out := newGatedWriter()
+	w := logging.NewAsyncWriter(out, 2, logging.DropOldest)
+	l := audit.NewLogger(w)
+
+	// The first record is taken by the blocked writer, then the queue
+	// is filled with an audit record ahead of a plain write
+	l.Log("audit record")
+	<-out.entered
+	l.Log("audit record")
+	w.Write([]byte("1\n"))
+	w.Write([]byte("2\n"))
+
+	close(out.gate)
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	lines := strings.Split(out.String(), "\n")
+	lines = lines[:len(lines)-1] // Don't want the empty line
+	if len(lines) != 3 || !strings.HasSuffix(lines[0], "audit record") || !strings.HasSuffix(lines[1], "audit record") || lines[2] != "2" {
+		t.Fatalf("expected both audit records and the newest write, got %q", lines)
+	}
+	if w.Dropped() != 1 {
+		t.Fatalf("expected 1 dropped, got %d", w.Dropped())
+	}
+}
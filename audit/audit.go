@@ -5,19 +5,21 @@
 package audit
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"os"
 
 	"github.com/whamcloud/logging/external"
+	"github.com/whamcloud/logging/record"
 )
 
 type (
-	// Logger wraps a *log.Logger with some configuration and
+	// Logger wraps a *record.Logger with some configuration and
 	// convenience methods
 	Logger struct {
-		log *log.Logger
+		log *record.Logger
 	}
 )
 
@@ -32,7 +34,7 @@ func init() {
 // NewLogger returns a *Logger
 func NewLogger(out io.Writer) *Logger {
 	return &Logger{
-		log: log.New(out, "", logFlags),
+		log: record.NewLogger(out, "audit", record.SeverityNotice, "", logFlags),
 	}
 }
 
@@ -41,9 +43,26 @@ func (l *Logger) SetOutput(out io.Writer) {
 	l.log.SetOutput(out)
 }
 
+// SetEncoder sets the encoder used to render log records
+func (l *Logger) SetEncoder(e record.Encoder) {
+	l.log.SetEncoder(e)
+}
+
 // Output writes the output for a logging event
 func (l *Logger) Output(skip int, s string) {
-	l.log.Output(skip, s)
+	l.log.Output(skip, record.Record{Message: s})
+}
+
+// OutputFields writes the output for a logging event with fields
+func (l *Logger) OutputFields(skip int, s string, fields record.Fields) {
+	l.log.Output(skip, record.Record{Message: s, Fields: fields})
+}
+
+// OutputPC writes r as if it had been logged by the callsite at pc,
+// for adapters such as a slog.Handler which have already captured
+// their caller
+func (l *Logger) OutputPC(pc uintptr, r record.Record) error {
+	return l.log.OutputPC(pc, r)
 }
 
 // Log outputs a log message from the arguments
@@ -56,6 +75,33 @@ func (l *Logger) Logf(f string, v ...interface{}) {
 	l.Output(3, fmt.Sprintf(f, v...))
 }
 
+// LogFields outputs a log message followed by the supplied alternating
+// keys and values, rendered in order as key=value pairs.
+func (l *Logger) LogFields(msg string, keysAndValues ...interface{}) {
+	l.OutputFields(3, msg, record.KV(keysAndValues...))
+}
+
+// LogCtx is like Log, but also emits the request ID, trace IDs and
+// fields attached to the context
+func (l *Logger) LogCtx(ctx context.Context, v ...interface{}) {
+	l.outputCtx(3, ctx, fmt.Sprint(v...))
+}
+
+// LogfCtx is like Logf, with the values attached to ctx
+func (l *Logger) LogfCtx(ctx context.Context, f string, v ...interface{}) {
+	l.outputCtx(3, ctx, fmt.Sprintf(f, v...))
+}
+
+// LogFieldsCtx is like LogFields, with the values attached to ctx
+// before the supplied keys and values
+func (l *Logger) LogFieldsCtx(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	l.outputCtx(3, ctx, msg, keysAndValues...)
+}
+
+func (l *Logger) outputCtx(skip int, ctx context.Context, msg string, keysAndValues ...interface{}) {
+	l.OutputFields(skip+1, msg, record.ContextFields(ctx).With(keysAndValues...))
+}
+
 // Writer returns a new *external.Writer suitable for injection into
 // 3rd-party logging packages.
 func (l *Logger) Writer() *external.Writer {
@@ -72,6 +118,11 @@ func (l *Logger) Write(data []byte) (int, error) {
 
 // package-level functions follow
 
+// StandardLogger returns the package-level logger
+func StandardLogger() *Logger {
+	return std
+}
+
 // Writer returns a new *external.Writer suitable for injection into
 // 3rd-party logging packages.
 func Writer() *external.Writer {
@@ -88,8 +139,34 @@ func Logf(f string, v ...interface{}) {
 	std.Output(3, fmt.Sprintf(f, v...))
 }
 
+// LogFields outputs a log message followed by the supplied alternating
+// keys and values, rendered in order as key=value pairs.
+func LogFields(msg string, keysAndValues ...interface{}) {
+	std.OutputFields(3, msg, record.KV(keysAndValues...))
+}
+
+// LogCtx calls LogCtx on the standard logger
+func LogCtx(ctx context.Context, v ...interface{}) {
+	std.outputCtx(3, ctx, fmt.Sprint(v...))
+}
+
+// LogfCtx calls LogfCtx on the standard logger
+func LogfCtx(ctx context.Context, f string, v ...interface{}) {
+	std.outputCtx(3, ctx, fmt.Sprintf(f, v...))
+}
+
+// LogFieldsCtx calls LogFieldsCtx on the standard logger
+func LogFieldsCtx(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	std.outputCtx(3, ctx, msg, keysAndValues...)
+}
+
 // SetOutput updates the io.Writer for the package as well as any external
 // writers created by the package
 func SetOutput(out io.Writer) {
 	std.SetOutput(out)
 }
+
+// SetEncoder sets the encoder used to render log records
+func SetEncoder(e record.Encoder) {
+	std.SetEncoder(e)
+}
@@ -6,11 +6,13 @@ package audit_test
 
 import (
 	"bytes"
+	"context"
 	"log"
 	"strings"
 	"testing"
 
 	"github.com/whamcloud/logging/audit"
+	"github.com/whamcloud/logging/record"
 )
 
 var testInputs = map[int]string{
@@ -81,3 +83,39 @@ func TestWriterWithLogger(t *testing.T) {
 		t.Fatalf("prefixes wrong: %s", lines[1])
 	}
 }
+
+func TestLogFields(t *testing.T) {
+	var buf bytes.Buffer
+	a := audit.NewLogger(&buf)
+
+	a.LogFields("quota changed", "user", "alice", "from", 10, "to", "20 GiB")
+
+	expected := `quota changed user=alice from=10 to="20 GiB"` + "\n"
+	if !strings.HasSuffix(buf.String(), expected) {
+		t.Fatalf("expected suffix %q, found %q", expected, buf.String())
+	}
+}
+
+func TestLogCtx(t *testing.T) {
+	var buf bytes.Buffer
+	a := audit.NewLogger(&buf)
+
+	ctx := record.WithRequestID(context.Background(), "r1")
+	a.LogCtx(ctx, testInputs[0])
+	a.LogFieldsCtx(ctx, testInputs[1], "user", "alice")
+
+	expected := []string{
+		testInputs[0] + " request_id=r1",
+		testInputs[1] + " request_id=r1 user=alice",
+	}
+	lines := strings.Split(buf.String(), "\n")
+	lines = lines[:len(lines)-1] // Don't want the empty line
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %q", len(expected), lines)
+	}
+	for i, line := range lines {
+		if !strings.HasSuffix(line, expected[i]) {
+			t.Fatalf("line %d: expected %s, found %s", i, expected[i], line)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/whamcloud/logging/record"
+)
+
+const (
+	// SeqField is the name of the field holding a record's sequence
+	// number when hash chaining is enabled.
+	SeqField = "seq"
+
+	// HashField is the name of the field holding a record's chained
+	// HMAC when hash chaining is enabled.
+	HashField = "hash"
+)
+
+type (
+	// ChainState identifies the last record in a hash chain, and may
+	// be used to resume the chain when appending to an existing file.
+	// When returned by VerifyChain, Restarts holds the line numbers at
+	// which a new chain was started after the first. A restart doesn't
+	// link back to the chain before it, so the records preceding each
+	// restart could have been truncated without detection.
+	ChainState struct {
+		Seq      uint64
+		Hash     []byte
+		Restarts []int
+	}
+
+	// ChainError describes the first record which failed verification
+	ChainError struct {
+		Line   int
+		Seq    uint64
+		Reason string
+	}
+
+	// hashChain implements record.Sealer, adding a sequence number and
+	// an HMAC chained to the previous record to every record.
+	hashChain struct {
+		key  []byte
+		seq  uint64
+		prev []byte
+	}
+)
+
+var (
+	// The chain fields are always the last ones in a record, so they
+	// are only accepted at the end of a line, where message text
+	// can't imitate them.
+	chainPattern = regexp.MustCompile(`(?:\bseq=(\d+) hash=([0-9a-f]{64})|"seq":(\d+),"hash":"([0-9a-f]{64})"\}\})$`)
+
+	hashPlaceholder = bytes.Repeat([]byte{'0'}, hex.EncodedLen(sha256.Size))
+)
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit chain broken at line %d (seq %d): %s", e.Line, e.Seq, e.Reason)
+}
+
+func newHashChain(key []byte, state ChainState) *hashChain {
+	prev := state.Hash
+	if len(prev) == 0 {
+		prev = make([]byte, sha256.Size)
+	}
+	return &hashChain{
+		key:  key,
+		seq:  state.Seq,
+		prev: prev,
+	}
+}
+
+func chainHash(key, prev, line []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(prev)
+	mac.Write(line)
+	return mac.Sum(nil)
+}
+
+// Prepare implements record.Sealer. Newlines in the message are
+// escaped, so that every chained record is a single line.
+func (c *hashChain) Prepare(r *record.Record) {
+	r.Message = strings.ReplaceAll(strings.TrimSuffix(r.Message, "\n"), "\n", `\n`)
+	c.seq++
+	r.Fields = r.Fields.With(SeqField, c.seq, HashField, string(hashPlaceholder))
+}
+
+// Seal implements record.Sealer
+func (c *hashChain) Seal(r *record.Record, line []byte) {
+	idx := bytes.LastIndex(line, hashPlaceholder)
+	if idx < 0 {
+		return
+	}
+
+	sum := chainHash(c.key, c.prev, bytes.TrimSuffix(line, []byte("\n")))
+	hex.Encode(line[idx:], sum)
+	c.prev = sum
+
+	// Keep the fields in step for RecordWriters which use them
+	for i := len(r.Fields) - 1; i >= 0; i-- {
+		if r.Fields[i].Key == HashField {
+			r.Fields[i].Value = string(line[idx : idx+len(hashPlaceholder)])
+			break
+		}
+	}
+}
+
+// EnableHashChain starts a new hash chain for the logger. Every record
+// subsequently written carries a sequence number and an HMAC of the
+// record chained to the previous one, keyed with the supplied key.
+func (l *Logger) EnableHashChain(key []byte) {
+	l.ResumeHashChain(key, ChainState{})
+}
+
+// ResumeHashChain continues an existing hash chain from the given
+// state, e.g. as returned by VerifyChainFile.
+func (l *Logger) ResumeHashChain(key []byte, state ChainState) {
+	l.log.SetSealer(newHashChain(key, state))
+}
+
+// DisableHashChain stops adding hash chain fields to records
+func (l *Logger) DisableHashChain() {
+	l.log.SetSealer(nil)
+}
+
+// EnableHashChain starts a new hash chain for the package logger
+func EnableHashChain(key []byte) {
+	std.EnableHashChain(key)
+}
+
+// ResumeHashChain continues an existing hash chain for the package logger
+func ResumeHashChain(key []byte, state ChainState) {
+	std.ResumeHashChain(key, state)
+}
+
+// VerifyChain reads hash-chained audit records and verifies that
+// each record's HMAC matches its contents and the preceding record,
+// and that sequence numbers are contiguous. A sequence number of 1
+// starts a new chain, as happens when a process restarts; the lines
+// at which this happens are reported in the returned state's Restarts.
+// The state of the last verified record is returned along with a
+// *ChainError describing the first broken, missing or reordered record.
+func VerifyChain(in io.Reader, key []byte) (ChainState, error) {
+	var state ChainState
+	var restarts []int
+	prev := make([]byte, sha256.Size)
+
+	// Every record is a single line. The exact bytes are kept, as the
+	// HMAC covers any carriage returns.
+	reader := bufio.NewReader(in)
+	lineNo := 0
+	for {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return state, err
+		}
+		if len(data) == 0 {
+			break
+		}
+		lineNo++
+		line := bytes.TrimSuffix(data, []byte("\n"))
+
+		m := chainPattern.FindSubmatchIndex(line)
+		if m == nil {
+			return state, &ChainError{Line: lineNo, Seq: state.Seq + 1, Reason: "record has no sequence number and hash"}
+		}
+		seqLoc, hashLoc := m[2:4], m[4:6]
+		if seqLoc[0] < 0 {
+			seqLoc, hashLoc = m[6:8], m[8:10]
+		}
+
+		seq, perr := strconv.ParseUint(string(line[seqLoc[0]:seqLoc[1]]), 10, 64)
+		if perr != nil {
+			return state, &ChainError{Line: lineNo, Seq: state.Seq + 1, Reason: perr.Error()}
+		}
+
+		switch {
+		case seq == 1:
+			if state.Seq > 0 {
+				restarts = append(restarts, lineNo)
+			}
+			prev = make([]byte, sha256.Size)
+		case seq > state.Seq+1:
+			return state, &ChainError{Line: lineNo, Seq: seq, Reason: fmt.Sprintf("missing records %d-%d", state.Seq+1, seq-1)}
+		case seq <= state.Seq:
+			return state, &ChainError{Line: lineNo, Seq: seq, Reason: fmt.Sprintf("record out of order (expected seq %d)", state.Seq+1)}
+		}
+
+		found := make([]byte, hex.DecodedLen(hashLoc[1]-hashLoc[0]))
+		hex.Decode(found, line[hashLoc[0]:hashLoc[1]])
+		copy(line[hashLoc[0]:hashLoc[1]], hashPlaceholder)
+
+		sum := chainHash(key, prev, line)
+		if !hmac.Equal(sum, found) {
+			return state, &ChainError{Line: lineNo, Seq: seq, Reason: "hash mismatch"}
+		}
+
+		prev = sum
+		state = ChainState{Seq: seq, Hash: sum, Restarts: restarts}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	return state, nil
+}
+
+// VerifyChainFile verifies the hash chain in the audit file at path
+func VerifyChainFile(path string, key []byte) (ChainState, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ChainState{}, err
+	}
+	defer f.Close()
+
+	return VerifyChain(f, key)
+}
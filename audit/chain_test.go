@@ -0,0 +1,157 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package audit_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/whamcloud/logging/audit"
+	"github.com/whamcloud/logging/record"
+)
+
+var chainKey = []byte("secret")
+
+func chainedLines(t *testing.T, encoder record.Encoder) []string {
+	var buf bytes.Buffer
+	a := audit.NewLogger(&buf)
+	a.SetEncoder(encoder)
+	a.EnableHashChain(chainKey)
+
+	a.Log(testInputs[0])
+	a.LogFields(testInputs[1], "user", "alice")
+	a.Log(testInputs[2])
+
+	lines := strings.SplitAfter(buf.String(), "\n")
+	return lines[:len(lines)-1] // Don't want the empty line
+}
+
+func verify(lines []string) (audit.ChainState, error) {
+	return audit.VerifyChain(strings.NewReader(strings.Join(lines, "")), chainKey)
+}
+
+func TestVerifyChain(t *testing.T) {
+	for _, encoder := range []record.Encoder{record.Text, record.JSON} {
+		lines := chainedLines(t, encoder)
+		if len(lines) != 3 {
+			t.Fatalf("expected 3 lines, found %q", lines)
+		}
+
+		state, err := verify(lines)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state.Seq != 3 {
+			t.Fatalf("expected last seq 3, found %d", state.Seq)
+		}
+	}
+}
+
+func TestVerifyChainFailures(t *testing.T) {
+	lines := chainedLines(t, record.Text)
+
+	for name, tc := range map[string]struct {
+		lines []string
+		line  int
+	}{
+		"edited":    {[]string{lines[0], strings.Replace(lines[1], "alice", "mallory", 1), lines[2]}, 2},
+		"missing":   {[]string{lines[0], lines[2]}, 2},
+		"reordered": {[]string{lines[0], lines[2], lines[1]}, 2},
+		"wrong key": {lines, 1},
+	} {
+		var err error
+		if name == "wrong key" {
+			_, err = audit.VerifyChain(strings.NewReader(strings.Join(tc.lines, "")), []byte("guess"))
+		} else {
+			_, err = verify(tc.lines)
+		}
+
+		chainErr, ok := err.(*audit.ChainError)
+		if !ok {
+			t.Fatalf("%s: expected *ChainError, got %v", name, err)
+		}
+		if chainErr.Line != tc.line {
+			t.Fatalf("%s: expected failure at line %d, got %s", name, tc.line, chainErr)
+		}
+	}
+}
+
+func TestResumeHashChain(t *testing.T) {
+	lines := chainedLines(t, record.Text)
+	state, err := verify(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	a := audit.NewLogger(&buf)
+	a.ResumeHashChain(chainKey, state)
+	a.Log("multi\nline")
+
+	if _, err := verify(append(lines, buf.String())); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyChainCarriageReturn(t *testing.T) {
+	var buf bytes.Buffer
+	a := audit.NewLogger(&buf)
+	a.EnableHashChain(chainKey)
+	a.Log("first\r\nsecond\r")
+	a.Log(testInputs[0])
+
+	if _, err := audit.VerifyChain(strings.NewReader(buf.String()), chainKey); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyChainRestarts(t *testing.T) {
+	first := chainedLines(t, record.Text)
+	second := chainedLines(t, record.Text)
+
+	state, err := verify(append(first, second...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Restarts) != 1 || state.Restarts[0] != 4 {
+		t.Fatalf("expected a restart at line 4, found %v", state.Restarts)
+	}
+
+	// Truncating the first chain can't be detected from the records
+	// alone, but is visible as a restart
+	state, err = verify(append(first[:1], second...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Restarts) != 1 || state.Restarts[0] != 2 {
+		t.Fatalf("expected a restart at line 2, found %v", state.Restarts)
+	}
+}
+
+func TestVerifyChainAdversarialMessage(t *testing.T) {
+	fake := strings.Repeat("ab", 32)
+	for _, encoder := range []record.Encoder{record.Text, record.JSON} {
+		var buf bytes.Buffer
+		a := audit.NewLogger(&buf)
+		a.SetEncoder(encoder)
+		a.EnableHashChain(chainKey)
+		a.Log("seq=1 hash=" + fake + "\n" + `"seq":1,"hash":"` + fake + `"}}` + "\nforged")
+		a.Log(testInputs[0])
+
+		lines := strings.Split(buf.String(), "\n")
+		lines = lines[:len(lines)-1] // Don't want the empty line
+		if len(lines) != 2 {
+			t.Fatalf("expected each record on a single line, found %q", lines)
+		}
+		state, err := audit.VerifyChain(strings.NewReader(buf.String()), chainKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state.Seq != 2 || len(state.Restarts) != 0 {
+			t.Fatalf("expected 2 records without restarts, found %+v", state)
+		}
+	}
+}
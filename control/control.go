@@ -0,0 +1,166 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package control allows debug output and display levels to be changed
+// in a running process, over a local unix socket or with signals.
+package control
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/whamcloud/logging"
+	"github.com/whamcloud/logging/applog"
+	"github.com/whamcloud/logging/debug"
+)
+
+// MaxVerbosity is the highest verbosity level reached by CycleVerbosity
+// before wrapping back to 1
+const MaxVerbosity = 5
+
+// State describes the current logging configuration
+type State struct {
+	Debug     bool
+	Verbosity int
+	Channels  string
+	Vmodule   string
+	Assert    string
+	Level     string
+}
+
+func (s State) String() string {
+	onOff := "off"
+	if s.Debug {
+		onOff = "on"
+	}
+	return fmt.Sprintf("debug=%s verbosity=%d channels=%q vmodule=%q assert=%s level=%s",
+		onOff, s.Verbosity, s.Channels, s.Vmodule, s.Assert, s.Level)
+}
+
+// lastChannels remembers the enabled channels while debugging is
+// toggled off, so that toggling it back on restores them
+var lastChannels = struct {
+	sync.Mutex
+	spec string
+}{}
+
+// Current returns the current logging configuration
+func Current() State {
+	channels := debug.EnabledChannels()
+	return State{
+		Debug:     debug.Enabled() || (channels != "" && channels != "false"),
+		Verbosity: debug.Verbosity(),
+		Channels:  channels,
+		Vmodule:   debug.Vmodule(),
+		Assert:    debug.CurrentAssertPolicy().String(),
+		Level:     applog.CurrentLevel().String(),
+	}
+}
+
+// ToggleDebug disables debug output if any is enabled, and otherwise
+// re-enables the channels which were enabled before it was last
+// toggled off (or all channels).
+func ToggleDebug() State {
+	lastChannels.Lock()
+	defer lastChannels.Unlock()
+
+	if Current().Debug {
+		lastChannels.spec = debug.EnabledChannels()
+		debug.EnableChannels("false")
+		return Current()
+	}
+
+	spec := lastChannels.spec
+	if spec == "" || spec == "false" {
+		spec = "true"
+	}
+	if err := debug.EnableChannels(spec); err != nil {
+		debug.EnableChannels("true")
+	}
+	return Current()
+}
+
+// CycleVerbosity increases the debug verbosity by one, wrapping back
+// to 1 after MaxVerbosity
+func CycleVerbosity() State {
+	level := debug.Verbosity() + 1
+	if level > MaxVerbosity || level < 1 {
+		level = 1
+	}
+	debug.SetVerbosity(level)
+	return Current()
+}
+
+// Execute runs a single control command and returns the resulting
+// state. The commands are:
+//
+//	status                   report the current state
+//	debug on|off|toggle      enable, disable or toggle all debug output
+//	debug <patterns>         enable the matching debug channels
+//	debug <patterns>@<time>  enable the channels for a limited time
+//	verbosity <n>            set the debug verbosity level
+//	vmodule <spec>           set the debug vmodule filter ("" clears it)
+//	assert <policy>          set the debug assertion policy, e.g. log
+//	level <name>             set the applog display level, e.g. TRACE
+//	reopen                   reopen log files
+//	dump                     dump the debug flight recorder to alerts
+func Execute(command string) (State, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return Current(), fmt.Errorf("empty command")
+	}
+	arg := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(command), fields[0]))
+
+	switch strings.ToLower(fields[0]) {
+	case "status":
+	case "debug":
+		switch strings.ToLower(arg) {
+		case "":
+			return Current(), fmt.Errorf("usage: debug on|off|toggle|<patterns>")
+		case "toggle":
+			return ToggleDebug(), nil
+		case "on":
+			arg = "true"
+		case "off":
+			arg = "false"
+		}
+		if err := debug.EnableChannels(arg); err != nil {
+			return Current(), err
+		}
+	case "verbosity":
+		level, err := strconv.Atoi(arg)
+		if err != nil {
+			return Current(), fmt.Errorf("invalid verbosity %q: %s", arg, err)
+		}
+		debug.SetVerbosity(level)
+	case "vmodule":
+		if err := debug.SetVmodule(strings.Trim(arg, `"`)); err != nil {
+			return Current(), err
+		}
+	case "assert":
+		policy, err := debug.ParseAssertPolicy(arg)
+		if err != nil {
+			return Current(), err
+		}
+		debug.SetAssertPolicy(policy)
+	case "level":
+		level, err := applog.ParseLevel(arg)
+		if err != nil {
+			return Current(), err
+		}
+		applog.SetLevel(level)
+	case "dump":
+		debug.DumpFlightRecorder()
+	case "reopen":
+		if err := logging.Reopen(); err != nil {
+			return Current(), err
+		}
+	default:
+		return Current(), fmt.Errorf("unknown command: %s", fields[0])
+	}
+
+	return Current(), nil
+}
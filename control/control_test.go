@@ -0,0 +1,125 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package control_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/whamcloud/logging/applog"
+	"github.com/whamcloud/logging/control"
+	"github.com/whamcloud/logging/debug"
+)
+
+func reset() {
+	debug.EnableChannels("false")
+	debug.SetVerbosity(debug.DefaultVerbosity)
+	debug.SetVmodule("")
+	debug.SetAssertPolicy(debug.AssertDebug)
+	applog.SetLevel(applog.USER)
+}
+
+func TestServer(t *testing.T) {
+	defer reset()
+
+	dir, err := ioutil.TempDir("", "logtest-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "control.sock")
+	s, err := control.Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != control.SocketMode {
+		t.Fatalf("expected mode %o, got %v (%v)", control.SocketMode, info.Mode(), err)
+	}
+	if entries, _ := ioutil.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("expected only the control socket in %s, found %d entries", dir, len(entries))
+	}
+
+	if _, err := control.Listen(path); err == nil {
+		t.Fatal("expected error for socket in use")
+	}
+
+	tests := []struct {
+		command  string
+		expected string
+	}{
+		{"status", "debug=off"},
+		{"debug on", "debug=on"},
+		{"verbosity 3", "verbosity=3"},
+		{"debug lnet.*,-lnet.noisy", `channels="lnet.*,-lnet.noisy"`},
+		{"vmodule file.go=2", `vmodule="file.go=2"`},
+		{"assert log", "assert=log"},
+		{"level trace", "level=TRACE"},
+		{"debug off", "debug=off"},
+	}
+	for _, tc := range tests {
+		reply, err := control.Send(path, tc.command)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.command, err)
+		}
+		if !strings.Contains(reply, tc.expected) {
+			t.Fatalf("%s: expected %s in reply, got %s", tc.command, tc.expected, reply)
+		}
+	}
+
+	for _, command := range []string{"bogus", "verbosity x", "level loud", "vmodule file.go"} {
+		if _, err := control.Send(path, command); err == nil {
+			t.Fatalf("%s: expected error", command)
+		}
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("expected control socket to be removed on close")
+	}
+}
+
+func TestHandleSignals(t *testing.T) {
+	defer reset()
+
+	states := make(chan control.State, 1)
+	stop := control.HandleSignals(func(s control.State) { states <- s })
+	defer stop()
+
+	wait := func(sig syscall.Signal) control.State {
+		syscall.Kill(os.Getpid(), sig)
+		select {
+		case s := <-states:
+			return s
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no state change after %s", sig)
+		}
+		return control.State{}
+	}
+
+	debug.EnableChannels("lnet.*")
+	if s := wait(syscall.SIGUSR1); s.Debug {
+		t.Fatalf("expected SIGUSR1 to disable debugging: %s", s)
+	}
+	if s := wait(syscall.SIGUSR1); !s.Debug || s.Channels != "lnet.*" {
+		t.Fatalf("expected SIGUSR1 to restore channels: %s", s)
+	}
+
+	debug.SetVerbosity(control.MaxVerbosity - 1)
+	if s := wait(syscall.SIGUSR2); s.Verbosity != control.MaxVerbosity {
+		t.Fatalf("expected verbosity %d: %s", control.MaxVerbosity, s)
+	}
+	if s := wait(syscall.SIGUSR2); s.Verbosity != 1 {
+		t.Fatalf("expected verbosity to wrap to 1: %s", s)
+	}
+}
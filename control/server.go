@@ -0,0 +1,233 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package control
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/whamcloud/logging/shutdown"
+)
+
+// SocketMode is the mode of the control socket, which is only
+// accessible to the process owner by default
+const SocketMode = 0600
+
+// Server accepts control commands on a unix socket. Each line sent to
+// the socket is run by Execute, and answered with a single line
+// containing either the resulting state or "error: " and the error.
+type Server struct {
+	path     string
+	listener net.Listener
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// Listen creates a control socket at path and starts serving commands.
+// A stale socket left at path by a previous process is replaced.
+func Listen(path string) (*Server, error) {
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("control socket %s is in use", path)
+	}
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+
+	listener, err := listenPrivate(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		path:     path,
+		listener: listener,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	shutdown.Track(s)
+
+	return s, nil
+}
+
+// listenPrivate creates the socket in a private directory and moves it
+// to path once its mode is set, so that it is never accessible to
+// other users with the umask's default permissions
+func listenPrivate(path string) (net.Listener, error) {
+	dir, err := ioutil.TempDir(filepath.Dir(path), ".control-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, "socket")
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmp, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	// The socket is removed from its final path by Close
+	listener.SetUnlinkOnClose(false)
+
+	if err := os.Chmod(tmp, SocketMode); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set mode of control socket: %s", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// Path returns the path of the control socket
+func (s *Server) Path() string {
+	return s.path
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.EqualFold(line, "quit") {
+			return
+		}
+
+		state, err := Execute(line)
+		if err != nil {
+			fmt.Fprintf(conn, "error: %s\n", err)
+			continue
+		}
+		fmt.Fprintln(conn, state)
+	}
+}
+
+// Close stops the server, disconnects any clients and removes the
+// control socket
+func (s *Server) Close() error {
+	shutdown.Untrack(s)
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return os.ErrClosed
+	}
+	s.closed = true
+	err := s.listener.Close()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	os.Remove(s.path)
+	return err
+}
+
+// Send connects to the control socket at path, runs a single command
+// and returns the reply
+func Send(path, command string) (string, error) {
+	conn, err := net.DialTimeout("unix", path, 5*time.Second)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	if _, err := fmt.Fprintln(conn, strings.TrimSpace(command)); err != nil {
+		return "", err
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	reply = strings.TrimSuffix(reply, "\n")
+	if strings.HasPrefix(reply, "error: ") {
+		return "", fmt.Errorf("%s", strings.TrimPrefix(reply, "error: "))
+	}
+	return reply, nil
+}
+
+// HandleSignals toggles debug output on SIGUSR1 and cycles the debug
+// verbosity on SIGUSR2. The optional callback is passed the state after
+// each change. The returned function stops signal handling.
+func HandleSignals(onChange func(State)) func() {
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	go func() {
+		for {
+			select {
+			case sig := <-ch:
+				var state State
+				if sig == syscall.SIGUSR1 {
+					state = ToggleDebug()
+				} else {
+					state = CycleVerbosity()
+				}
+				if onChange != nil {
+					onChange(state)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
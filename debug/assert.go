@@ -0,0 +1,201 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package debug
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/whamcloud/logging/alert"
+	"github.com/whamcloud/logging/record"
+)
+
+// AssertPolicy determines what happens when an assertion fails
+type AssertPolicy int32
+
+const (
+	// AssertDebug panics if the debugger is enabled, and otherwise
+	// ignores the failure. This is the default.
+	AssertDebug AssertPolicy = iota
+	// AssertIgnore ignores failures
+	AssertIgnore
+	// AssertLog logs failures to the debug output and continues
+	AssertLog
+	// AssertStack logs failures with a stack trace and continues
+	AssertStack
+	// AssertPanic logs failures and panics, whether or not the
+	// debugger is enabled
+	AssertPanic
+	// AssertAlert logs failures to the alert output and continues
+	AssertAlert
+)
+
+// AssertEnvVar is the name of an environment variable that, if set,
+// selects the assertion policy by name, e.g. DEBUG_ASSERT=log
+const AssertEnvVar = "DEBUG_ASSERT"
+
+// DefaultAssertInterval is the default minimum interval between
+// reports of failures from the same assertion, when continuing
+const DefaultAssertInterval = time.Minute
+
+var assertion = struct {
+	policy   int32
+	stacks   int32
+	interval int64
+	sites    sync.Map // uintptr -> *assertSite
+}{
+	interval: int64(DefaultAssertInterval),
+}
+
+// assertSite tracks reports of failures from a single assertion
+type assertSite struct {
+	sync.Mutex
+	last       time.Time
+	suppressed int
+}
+
+func (p AssertPolicy) String() string {
+	switch p {
+	case AssertDebug:
+		return "debug"
+	case AssertIgnore:
+		return "ignore"
+	case AssertLog:
+		return "log"
+	case AssertStack:
+		return "stack"
+	case AssertPanic:
+		return "panic"
+	case AssertAlert:
+		return "alert"
+	default:
+		return fmt.Sprintf("Unknown policy: %d", int(p))
+	}
+}
+
+// ParseAssertPolicy returns the AssertPolicy with the given name
+func ParseAssertPolicy(name string) (AssertPolicy, error) {
+	for p := AssertDebug; p <= AssertAlert; p++ {
+		if strings.EqualFold(name, p.String()) {
+			return p, nil
+		}
+	}
+	return AssertDebug, fmt.Errorf("unknown assertion policy: %s", name)
+}
+
+// SetAssertPolicy sets the policy for assertion failures in every
+// debugger
+func SetAssertPolicy(p AssertPolicy) {
+	atomic.StoreInt32(&assertion.policy, int32(p))
+}
+
+// CurrentAssertPolicy returns the policy for assertion failures
+func CurrentAssertPolicy() AssertPolicy {
+	return AssertPolicy(atomic.LoadInt32(&assertion.policy))
+}
+
+// SetAssertStacks sets which goroutine stacks are attached to reports
+// of failed assertions. The stack policy always attaches at least the
+// current goroutine's stack.
+func SetAssertStacks(mode record.StackMode) {
+	atomic.StoreInt32(&assertion.stacks, int32(mode))
+}
+
+// SetAssertRateLimit sets the minimum interval between reports of
+// failures from the same assertion under the log, stack and alert
+// policies. Failures in between are counted and the count is included
+// in the next report. An interval of 0 reports every failure.
+func SetAssertRateLimit(interval time.Duration) {
+	atomic.StoreInt64(&assertion.interval, int64(interval))
+}
+
+// ignored indicates whether a failed assertion needs no further work,
+// so that its message need not be formatted
+func (d *Debugger) ignored() bool {
+	switch CurrentAssertPolicy() {
+	case AssertIgnore:
+		return true
+	case AssertDebug:
+		return !d.Enabled()
+	default:
+		return false
+	}
+}
+
+// allowReport applies the rate limit for the assertion at pc, and
+// returns the number of failures suppressed since its last report
+func allowReport(pc uintptr) (int, bool) {
+	interval := time.Duration(atomic.LoadInt64(&assertion.interval))
+	if interval <= 0 {
+		return 0, true
+	}
+
+	v, _ := assertion.sites.LoadOrStore(pc, &assertSite{})
+	site := v.(*assertSite)
+	site.Lock()
+	defer site.Unlock()
+
+	now := time.Now()
+	if !site.last.IsZero() && now.Sub(site.last) < interval {
+		site.suppressed++
+		return 0, false
+	}
+	suppressed := site.suppressed
+	site.last, site.suppressed = now, 0
+	return suppressed, true
+}
+
+// assertFailed handles a failed assertion according to the policy. The
+// skip has the same meaning as for Output.
+func (d *Debugger) assertFailed(skip int, msg string) {
+	policy := CurrentAssertPolicy()
+	pc := record.CallerPC(skip + 1)
+	r := record.Record{Severity: record.SeverityError, Message: msg}
+
+	switch policy {
+	case AssertIgnore:
+		return
+	case AssertDebug, AssertPanic:
+		if policy == AssertDebug && !d.Enabled() {
+			return
+		}
+		r.Stack = record.Stack(assertStacks(policy))
+		d.log.OutputPC(pc, r)
+		DumpFlightRecorder()
+		panic(msg)
+	}
+
+	suppressed, ok := allowReport(pc)
+	if !ok {
+		return
+	}
+	if suppressed > 0 {
+		r.Message = fmt.Sprintf("%s (%d more failures suppressed)", msg, suppressed)
+	}
+	r.Stack = record.Stack(assertStacks(policy))
+
+	if policy != AssertAlert {
+		d.log.OutputPC(pc, r)
+		return
+	}
+
+	r.Time, r.Stream, r.File = time.Now(), "alert", "???"
+	if frame, ok := record.CallerFrame(pc); ok {
+		r.File, r.Line = frame.File, frame.Line
+	}
+	alert.WriteRecord(&r)
+}
+
+// assertStacks returns the stacks to attach for the given policy
+func assertStacks(policy AssertPolicy) record.StackMode {
+	mode := record.StackMode(atomic.LoadInt32(&assertion.stacks))
+	if policy == AssertStack && mode == record.NoStack {
+		return record.CurrentStack
+	}
+	return mode
+}
@@ -0,0 +1,274 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package debug
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/whamcloud/logging/record"
+)
+
+// DefaultChannel is the name of the package-level debugger when
+// matching channel patterns
+const DefaultChannel = "default"
+
+type (
+	// channelPattern is a single entry in a channel specification
+	channelPattern struct {
+		negate bool
+		glob   string
+		re     *regexp.Regexp
+	}
+)
+
+var channels = struct {
+	sync.Mutex
+	m         map[string]*Debugger
+	patterns  []channelPattern
+	spec      string
+	out       io.Writer
+	encoder   record.Encoder
+	verbosity int
+	vmodule   *vmoduleFilter
+}{m: make(map[string]*Debugger), verbosity: DefaultVerbosity}
+
+func (p channelPattern) match(name string) bool {
+	if p.re != nil {
+		return p.re.MatchString(name)
+	}
+	matched, _ := path.Match(p.glob, name)
+	return matched
+}
+
+// parseSwitch parses a boolean which enables or disables every channel.
+// As well as the values accepted by strconv.ParseBool, it accepts "yes",
+// "no", "on" and "off" in any case.
+func parseSwitch(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "yes", "on":
+		return true, nil
+	case "no", "off":
+		return false, nil
+	}
+	return strconv.ParseBool(strings.TrimSpace(s))
+}
+
+// parseChannels parses a comma-separated list of channel patterns. Each
+// pattern is a glob (e.g. "lnet.*") or a regular expression between
+// slashes (e.g. "/^lnet\.(router|peer)$/"), and may be prefixed with
+// "-" to disable matching channels. A boolean value enables (e.g.
+// "true" or "yes") or disables ("false" or "off") every channel.
+func parseChannels(spec string) ([]channelPattern, error) {
+	if b, err := parseSwitch(spec); err == nil {
+		if b {
+			return []channelPattern{{glob: "*"}}, nil
+		}
+		return nil, nil
+	}
+
+	var patterns []channelPattern
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		var p channelPattern
+		if strings.HasPrefix(item, "-") {
+			p.negate = true
+			item = item[1:]
+		} else if strings.HasPrefix(item, "+") {
+			item = item[1:]
+		}
+
+		if len(item) > 1 && strings.HasPrefix(item, "/") && strings.HasSuffix(item, "/") {
+			re, err := regexp.Compile(item[1 : len(item)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid debug channel pattern %q: %s", item, err)
+			}
+			p.re = re
+		} else {
+			if item == "all" {
+				item = "*"
+			}
+			if _, err := path.Match(item, ""); err != nil {
+				return nil, fmt.Errorf("invalid debug channel pattern %q: %s", item, err)
+			}
+			p.glob = item
+		}
+		patterns = append(patterns, p)
+	}
+
+	return patterns, nil
+}
+
+// channelEnabled must be called with the channels lock held. Later
+// patterns take precedence over earlier ones.
+func channelEnabled(name string) bool {
+	enabled := false
+	for _, p := range channels.patterns {
+		if p.match(name) {
+			enabled = !p.negate
+		}
+	}
+	return enabled
+}
+
+// Channel returns the *Debugger for the named subsystem, creating it if
+// necessary. Channels are enabled and disabled by EnableChannels(), and
+// share the package-level debugger's output and encoder.
+func Channel(name string) *Debugger {
+	if name == DefaultChannel {
+		return std
+	}
+
+	channels.Lock()
+	defer channels.Unlock()
+
+	if d, ok := channels.m[name]; ok {
+		return d
+	}
+
+	d := &Debugger{
+		name:      name,
+		log:       record.NewLogger(channels.out, "debug", record.SeverityDebug, "DEBUG ["+name+"] ", log.Lmicroseconds|log.Lshortfile),
+		verbosity: int32(channels.verbosity),
+	}
+	if channels.encoder != nil {
+		d.log.SetEncoder(channels.encoder)
+	}
+	d.vmodule.Store(channels.vmodule)
+	if channelEnabled(name) {
+		d.Enable()
+	}
+	channels.m[name] = d
+
+	return d
+}
+
+// EnableChannels enables the channels matching the comma-separated
+// list of patterns and disables the rest, e.g. "lnet.*,-lnet.noisy".
+// The package-level debugger is matched as DefaultChannel. A number
+// enables every channel at that verbosity level (0 disables them), and
+// a duration suffix (e.g. "lnet.*@30m") limits how long the channels
+// stay enabled, as with EnableChannelsFor. The same syntax is accepted
+// by the ENABLE_DEBUG environment variable and the -debug flag.
+func EnableChannels(spec string) error {
+	if channelSpec, duration, ok, err := splitDuration(spec); err != nil {
+		return err
+	} else if ok {
+		return EnableChannelsFor(channelSpec, duration)
+	}
+
+	cancelTimedChannels()
+	return enableChannels(spec)
+}
+
+func enableChannels(spec string) error {
+	if level, err := strconv.Atoi(strings.TrimSpace(spec)); err == nil {
+		SetVerbosity(level)
+		spec = strconv.FormatBool(level > 0)
+	}
+
+	patterns, err := parseChannels(spec)
+	if err != nil {
+		return err
+	}
+
+	channels.Lock()
+	defer channels.Unlock()
+
+	channels.patterns = patterns
+	channels.spec = spec
+	for name, d := range channels.m {
+		if channelEnabled(name) {
+			d.Enable()
+		} else {
+			d.Disable()
+		}
+	}
+	if channelEnabled(DefaultChannel) {
+		std.Enable()
+	} else {
+		std.Disable()
+	}
+
+	return nil
+}
+
+// EnabledChannels returns the channel patterns last passed to
+// EnableChannels, or a boolean value if all channels were enabled or
+// disabled
+func EnabledChannels() string {
+	channels.Lock()
+	defer channels.Unlock()
+	return channels.spec
+}
+
+// ChannelNames returns the sorted names of the channels created by
+// Channel()
+func ChannelNames() []string {
+	channels.Lock()
+	defer channels.Unlock()
+
+	names := make([]string, 0, len(channels.m))
+	for name := range channels.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setChannelOutput updates the output of every channel
+func setChannelOutput(out io.Writer) {
+	channels.Lock()
+	defer channels.Unlock()
+
+	channels.out = out
+	for _, d := range channels.m {
+		d.SetOutput(out)
+	}
+}
+
+// setChannelEncoder updates the encoder of every channel
+func setChannelEncoder(e record.Encoder) {
+	channels.Lock()
+	defer channels.Unlock()
+
+	channels.encoder = e
+	for _, d := range channels.m {
+		d.SetEncoder(e)
+	}
+}
+
+// setChannelVerbosity updates the verbosity of every channel
+func setChannelVerbosity(level int) {
+	channels.Lock()
+	defer channels.Unlock()
+
+	channels.verbosity = level
+	for _, d := range channels.m {
+		d.SetVerbosity(level)
+	}
+}
+
+// setChannelVmodule updates the vmodule filter of every channel
+func setChannelVmodule(f *vmoduleFilter) {
+	channels.Lock()
+	defer channels.Unlock()
+
+	channels.vmodule = f
+	for _, d := range channels.m {
+		d.vmodule.Store(f)
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package debug
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/whamcloud/logging/alert"
+)
+
+type (
+	// deadline reverts a time-limited enablement. The state saved is
+	// the one from before the first EnableFor, so extending the time
+	// limit still returns to the original state.
+	deadline struct {
+		sync.Mutex
+		timer      *time.Timer
+		wasEnabled bool
+	}
+
+	// channelState is a snapshot of the channel configuration
+	channelState struct {
+		spec       string
+		verbosity  int
+		stdEnabled bool
+	}
+)
+
+var timedChannels = struct {
+	sync.Mutex
+	timer *time.Timer
+	prev  channelState
+}{}
+
+// describe returns a name for the debugger in alert messages
+func (d *Debugger) describe() string {
+	if d.name == "" || d.name == DefaultChannel {
+		return "debug output"
+	}
+	return fmt.Sprintf("debug channel %s", d.name)
+}
+
+// EnableFor enables the debugger for the given duration, after which it
+// returns to its previous state. An alert is logged when debugging is
+// switched on and when it is switched back. Calling EnableFor again
+// before the deadline sets a new deadline.
+func (d *Debugger) EnableFor(duration time.Duration) {
+	d.deadline.Lock()
+	defer d.deadline.Unlock()
+
+	if d.deadline.timer == nil {
+		d.deadline.wasEnabled = d.Enabled()
+	} else {
+		d.deadline.timer.Stop()
+	}
+	d.Enable()
+	alert.Warnf("%s enabled for %s", d.describe(), duration)
+
+	var timer *time.Timer
+	timer = time.AfterFunc(duration, func() {
+		d.deadline.Lock()
+		defer d.deadline.Unlock()
+
+		if d.deadline.timer != timer {
+			return
+		}
+		d.deadline.timer = nil
+		if !d.deadline.wasEnabled {
+			alert.Warnf("%s disabled after %s", d.describe(), duration)
+			d.Disable()
+		}
+	})
+	d.deadline.timer = timer
+}
+
+// EnableFor enables debug logging for the given duration
+func EnableFor(duration time.Duration) {
+	std.EnableFor(duration)
+}
+
+// EnableChannelsFor is like EnableChannels, but returns to the previous
+// channel configuration and verbosity after the given duration. An
+// alert is logged when the channels are enabled and when they revert.
+func EnableChannelsFor(spec string, duration time.Duration) error {
+	timedChannels.Lock()
+	defer timedChannels.Unlock()
+
+	prev := channelState{
+		spec:       EnabledChannels(),
+		verbosity:  Verbosity(),
+		stdEnabled: Enabled(),
+	}
+	if err := enableChannels(spec); err != nil {
+		return err
+	}
+
+	if timedChannels.timer == nil {
+		timedChannels.prev = prev
+	} else {
+		timedChannels.timer.Stop()
+	}
+	alert.Warnf("debug channels %q enabled for %s", spec, duration)
+
+	var timer *time.Timer
+	timer = time.AfterFunc(duration, func() {
+		timedChannels.Lock()
+		defer timedChannels.Unlock()
+
+		if timedChannels.timer != timer {
+			return
+		}
+		timedChannels.timer = nil
+		alert.Warnf("debug channels %q disabled after %s", spec, duration)
+		timedChannels.prev.restore()
+	})
+	timedChannels.timer = timer
+
+	return nil
+}
+
+// cancelTimedChannels stops any pending return to a previous channel
+// configuration, so that an explicit configuration is not reverted
+func cancelTimedChannels() {
+	timedChannels.Lock()
+	defer timedChannels.Unlock()
+
+	if timedChannels.timer != nil {
+		timedChannels.timer.Stop()
+		timedChannels.timer = nil
+	}
+}
+
+func (s channelState) restore() {
+	SetVerbosity(s.verbosity)
+	spec := s.spec
+	if spec == "" {
+		spec = "false"
+	}
+	enableChannels(spec)
+
+	// The default debugger may have been enabled or disabled on its own
+	if s.stdEnabled {
+		std.Enable()
+	} else {
+		std.Disable()
+	}
+}
+
+// splitDuration splits a "spec@duration" channel specification. The
+// duration is only split off if the spec is not a regular expression
+// which happens to contain an "@".
+func splitDuration(spec string) (string, time.Duration, bool, error) {
+	i := strings.LastIndexByte(spec, '@')
+	if i < 0 {
+		return spec, 0, false, nil
+	}
+
+	duration, err := time.ParseDuration(strings.TrimSpace(spec[i+1:]))
+	if err != nil {
+		if strings.HasSuffix(strings.TrimSpace(spec), "/") {
+			return spec, 0, false, nil
+		}
+		return spec, 0, false, fmt.Errorf("invalid debug duration in %q: %s", spec, err)
+	}
+	if duration <= 0 {
+		return spec, 0, false, fmt.Errorf("debug duration must be positive: %s", spec)
+	}
+
+	return spec[:i], duration, true, nil
+}
@@ -5,23 +5,38 @@
 package debug
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
+	"runtime"
 	"strconv"
 	"sync/atomic"
 
 	"github.com/whamcloud/logging/external"
+	"github.com/whamcloud/logging/record"
+	"github.com/whamcloud/logging/shutdown"
 )
 
 type (
-	// Debugger wraps a *log.Logger with some configuration and
+	// Debugger wraps a *record.Logger with some configuration and
 	// convenience methods
 	Debugger struct {
-		log     *log.Logger
-		enabled int32
+		name      string
+		log       *record.Logger
+		enabled   int32
+		verbosity int32
+		vmodule   atomic.Value // *vmoduleFilter
+		deadline  deadline
+	}
+
+	// Verbose is returned by V() and logs only if the requested
+	// verbosity level is enabled
+	Verbose struct {
+		d     *Debugger
+		write bool
 	}
 
 	// Flag allows the flag package to enable debugging
@@ -31,21 +46,55 @@ type (
 var std *Debugger
 
 // EnableEnvVar is the name of an environment variable that, if set, will
-// enable this package's functionality.
+// enable this package's functionality. Its value may be a verbosity
+// level, a boolean such as "yes", or a list of channel patterns, as
+// accepted by EnableChannels().
 const EnableEnvVar = "ENABLE_DEBUG"
 
+// DefaultVerbosity is the verbosity level of a new *Debugger
+const DefaultVerbosity = 1
+
 func init() {
 	std = NewDebugger(os.Stderr)
-
-	if os.Getenv(EnableEnvVar) != "" {
-		Enable()
+	std.name = DefaultChannel
+	channels.out = os.Stderr
+
+	if spec := os.Getenv(EnableEnvVar); spec != "" {
+		// Channels created later are matched against the patterns,
+		// so the spec is kept even if none of them exist yet. An
+		// invalid spec turns on the default debugger, as any value
+		// always has.
+		if err := EnableChannels(spec); err != nil {
+			fmt.Fprintf(os.Stderr, "ignoring %s channels: %s\n", EnableEnvVar, err)
+			Enable()
+		}
+	}
+	if spec := os.Getenv(VmoduleEnvVar); spec != "" {
+		if err := SetVmodule(spec); err != nil {
+			fmt.Fprintf(os.Stderr, "ignoring %s: %s\n", VmoduleEnvVar, err)
+		}
 	}
+	if size, err := strconv.Atoi(os.Getenv(FlightRecorderEnvVar)); err == nil {
+		EnableFlightRecorder(size)
+	}
+	if name := os.Getenv(AssertEnvVar); name != "" {
+		policy, err := ParseAssertPolicy(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ignoring %s: %s\n", AssertEnvVar, err)
+		}
+		SetAssertPolicy(policy)
+	}
+
+	shutdown.RegisterFatalHook(DumpFlightRecorder)
 }
 
-// FlagVar returns a tuple of parameters suitable for flag.Var()
+// FlagVar returns a tuple of parameters suitable for flag.Var(). The
+// flag may be given alone, with a verbosity level (e.g. -debug=3), or
+// with a list of channel patterns (e.g. -debug=lnet.*,-lnet.noisy),
+// and either may be time-limited (e.g. -debug=3@30m).
 func FlagVar() (*Flag, string, string) {
 	f := Flag(false)
-	return &f, "debug", "enable debug output"
+	return &f, "debug", "enable debug output, optionally at a verbosity level or for matching channels"
 }
 
 // IsBoolFlag satisfies the flag.boolFlag interface
@@ -59,23 +108,33 @@ func (f *Flag) String() string {
 
 // Set satisfies the flag.Value interface
 func (f *Flag) Set(value string) error {
-	b, err := strconv.ParseBool(value)
-	if err == nil {
-		if b {
-			std.Enable()
-		}
-		f = (*Flag)(&b)
+	if err := EnableChannels(value); err != nil {
+		return err
+	}
+
+	if level, err := strconv.Atoi(value); err == nil {
+		*f = Flag(level > 0)
+	} else if b, err := parseSwitch(value); err == nil {
+		*f = Flag(b)
+	} else {
+		*f = Flag(true)
 	}
-	return err
+	return nil
 }
 
 // NewDebugger creates a new *Debugger which logs to the supplied io.Writer
 func NewDebugger(out io.Writer) *Debugger {
 	return &Debugger{
-		log: log.New(out, "DEBUG ", log.Lmicroseconds|log.Lshortfile),
+		log:       record.NewLogger(out, "debug", record.SeverityDebug, "DEBUG ", log.Lmicroseconds|log.Lshortfile),
+		verbosity: DefaultVerbosity,
 	}
 }
 
+// Name returns the debugger's channel name, if any
+func (d *Debugger) Name() string {
+	return d.name
+}
+
 // Enabled indicates whether or not debugging is enabled
 func (d *Debugger) Enabled() bool {
 	return atomic.LoadInt32(&d.enabled) == 1
@@ -91,17 +150,148 @@ func (d *Debugger) Disable() {
 	atomic.CompareAndSwapInt32(&d.enabled, 1, 0)
 }
 
+// SetVerbosity sets the highest level at which V() will log
+func (d *Debugger) SetVerbosity(level int) {
+	atomic.StoreInt32(&d.verbosity, int32(level))
+}
+
+// Verbosity returns the debugger's verbosity level
+func (d *Debugger) Verbosity() int {
+	return int(atomic.LoadInt32(&d.verbosity))
+}
+
+// enabledAt indicates whether the debugger is enabled at the given
+// verbosity level, ignoring any vmodule filter
+func (d *Debugger) enabledAt(level int) bool {
+	return d.Enabled() && atomic.LoadInt32(&d.verbosity) >= int32(level)
+}
+
+// selected indicates whether the vmodule filter enables the caller skip
+// frames up, so that callers can skip formatting for disabled callsites
+func (d *Debugger) selected(skip int) bool {
+	f := d.filter()
+	if f == nil {
+		return false
+	}
+	return d.allowed(f, record.CallerPC(skip+1), 0)
+}
+
+// allowed indicates whether the callsite at pc may log at level
+func (d *Debugger) allowed(f *vmoduleFilter, pc uintptr, level int) bool {
+	if vlevel := f.level(pc); vlevel != noMatch {
+		return level <= vlevel
+	}
+	return d.enabledAt(level)
+}
+
+// V returns a Verbose which logs only if the debugger is enabled and
+// its verbosity is at least level, e.g. d.V(3).Printf("%x", packet)
+func (d *Debugger) V(level int) Verbose {
+	return d.v(3, level)
+}
+
+func (d *Debugger) v(skip, level int) Verbose {
+	f := d.filter()
+	if f == nil {
+		if d.enabledAt(level) {
+			return Verbose{d: d, write: true}
+		}
+	} else if d.allowed(f, record.CallerPC(skip), level) {
+		return Verbose{d: d, write: true}
+	}
+
+	// Disabled levels are still kept by the flight recorder, up to
+	// the debugger's verbosity
+	if recorder() != nil && d.Verbosity() >= level {
+		return Verbose{d: d}
+	}
+	return Verbose{}
+}
+
+// Enabled indicates whether or not the verbosity level is enabled
+func (vb Verbose) Enabled() bool {
+	return vb.write
+}
+
+// Printf outputs formatted arguments
+func (vb Verbose) Printf(f string, v ...interface{}) {
+	if vb.d == nil {
+		return
+	}
+	vb.d.emit(record.CallerPC(2), record.Record{Message: fmt.Sprintf(f, v...)}, vb.write)
+}
+
+// Print outputs the arguments
+func (vb Verbose) Print(v ...interface{}) {
+	if vb.d == nil {
+		return
+	}
+	vb.d.emit(record.CallerPC(2), record.Record{Message: fmt.Sprint(v...)}, vb.write)
+}
+
 // Output writes the output for a logging event
 func (d *Debugger) Output(skip int, s string) {
-	if !d.Enabled() {
+	d.output(skip+1, record.Record{Message: s})
+}
+
+// output logs r if the debugger is enabled, or if its vmodule filter
+// enables the caller, and passes it to any flight recorder
+func (d *Debugger) output(skip int, r record.Record) {
+	f, fr := d.filter(), recorder()
+	if f == nil && fr == nil {
+		if !d.Enabled() {
+			return
+		}
+		d.log.Output(skip, r)
+		return
+	}
+
+	pc := record.CallerPC(skip)
+	write := d.Enabled()
+	if f != nil {
+		write = d.allowed(f, pc, 0)
+	}
+	if !write && fr == nil {
+		return
+	}
+	d.emit(pc, r, write)
+}
+
+// emit passes a record to any flight recorder, and writes it if the
+// caller is enabled
+func (d *Debugger) emit(pc uintptr, r record.Record, write bool) {
+	if fr := recorder(); fr != nil {
+		fr.add(d.name, pc, r.Message, r.Fields)
+	}
+	if write {
+		d.log.OutputPC(pc, r)
+	}
+}
+
+// OutputPC logs r as if it had been logged at the verbosity level by
+// the callsite at pc, for adapters such as a slog.Handler which have
+// already captured their caller
+func (d *Debugger) OutputPC(pc uintptr, level int, r record.Record) {
+	write := d.enabledAt(level)
+	if f := d.filter(); f != nil {
+		write = d.allowed(f, pc, level)
+	}
+	if !write && (recorder() == nil || d.Verbosity() < level) {
 		return
 	}
-	d.log.Output(skip, s)
+	d.emit(pc, r, write)
+}
+
+// Active indicates whether any callsite could log at the verbosity
+// level, either because it is enabled, a vmodule filter may enable it
+// or the flight recorder would keep it
+func (d *Debugger) Active(level int) bool {
+	return d.enabledAt(level) || d.filter() != nil || (recorder() != nil && d.Verbosity() >= level)
 }
 
 // Printf outputs formatted arguments
 func (d *Debugger) Printf(f string, v ...interface{}) {
-	if !d.Enabled() {
+	if !d.Enabled() && recorder() == nil && !d.selected(2) {
 		return
 	}
 	d.Output(3, fmt.Sprintf(f, v...))
@@ -109,36 +299,65 @@ func (d *Debugger) Printf(f string, v ...interface{}) {
 
 // Print outputs the arguments
 func (d *Debugger) Print(v ...interface{}) {
-	if !d.Enabled() {
+	if !d.Enabled() && recorder() == nil && !d.selected(2) {
 		return
 	}
 	d.Output(3, fmt.Sprint(v...))
 }
 
+// PrintfCtx is like Printf, but also emits the request ID, trace IDs
+// and fields attached to the context
+func (d *Debugger) PrintfCtx(ctx context.Context, f string, v ...interface{}) {
+	d.printCtx(3, ctx, func() string { return fmt.Sprintf(f, v...) })
+}
+
+// PrintCtx is like Print, with the values attached to ctx
+func (d *Debugger) PrintCtx(ctx context.Context, v ...interface{}) {
+	d.printCtx(3, ctx, func() string { return fmt.Sprint(v...) })
+}
+
+// printCtx only formats the message if it may be logged
+func (d *Debugger) printCtx(skip int, ctx context.Context, msg func() string) {
+	if !d.Enabled() && recorder() == nil && !d.selected(skip) {
+		return
+	}
+	d.output(skip+1, record.Record{Message: msg(), Fields: record.ContextFields(ctx)})
+}
+
 // Assertf accepts a boolean expression and formatted arguments, which
-// if the expression is false, will be printed before panicing.
+// if the expression is false, are handled according to the assertion
+// policy (by default, printed before panicing if debugging is enabled).
 func (d *Debugger) Assertf(expr bool, f string, v ...interface{}) {
-	if !d.Enabled() {
+	if expr || d.ignored() {
 		return
 	}
-	if !expr {
-		msg := fmt.Sprintf("ASSERTION FAILED: "+f, v...)
-		d.Output(3, msg)
-		panic(msg)
-	}
+	d.assertFailed(2, fmt.Sprintf("ASSERTION FAILED: "+f, v...))
 }
 
 // Assert accepts a boolean expression and arguments, which if the
-// expression is false, will be printed before panicing.
+// expression is false, are handled according to the assertion policy
+// (by default, printed before panicing if debugging is enabled).
 func (d *Debugger) Assert(expr bool, v ...interface{}) {
-	if !d.Enabled() {
+	if expr || d.ignored() {
 		return
 	}
-	if !expr {
-		msg := fmt.Sprintf("ASSERTION FAILED: %s", fmt.Sprint(v...))
-		d.Output(3, msg)
-		panic(msg)
+	d.assertFailed(2, fmt.Sprintf("ASSERTION FAILED: %s", fmt.Sprint(v...)))
+}
+
+// DumpGoroutines writes the stacks of every goroutine through the
+// debugger, if it is enabled
+func (d *Debugger) DumpGoroutines() {
+	d.dumpGoroutines(3)
+}
+
+func (d *Debugger) dumpGoroutines(calldepth int) {
+	if !d.Enabled() {
+		return
 	}
+	d.log.Output(calldepth, record.Record{
+		Message: fmt.Sprintf("goroutine dump (%d goroutines)", runtime.NumGoroutine()),
+		Stack:   record.Stack(record.AllStacks),
+	})
 }
 
 // SetOutput configures the output writer for the debugger's logger
@@ -146,6 +365,11 @@ func (d *Debugger) SetOutput(out io.Writer) {
 	d.log.SetOutput(out)
 }
 
+// SetEncoder sets the encoder used to render log records
+func (d *Debugger) SetEncoder(e record.Encoder) {
+	d.log.SetEncoder(e)
+}
+
 // Writer returns a new *external.Writer suitable for injection into
 // 3rd-party logging packages.
 func (d *Debugger) Writer() *external.Writer {
@@ -168,9 +392,18 @@ func Writer() *external.Writer {
 	return std.Writer()
 }
 
-// SetOutput configures the output writer for the wrapped *log.Logger
+// SetOutput configures the output writer for the wrapped *record.Logger
+// and for every channel
 func SetOutput(out io.Writer) {
 	std.SetOutput(out)
+	setChannelOutput(out)
+}
+
+// SetEncoder sets the encoder used to render log records for the
+// package-level debugger and every channel
+func SetEncoder(e record.Encoder) {
+	std.SetEncoder(e)
+	setChannelEncoder(e)
 }
 
 // Enable enables debug logging
@@ -188,6 +421,24 @@ func Enabled() bool {
 	return std.Enabled()
 }
 
+// SetVerbosity sets the verbosity level of the package-level debugger
+// and every channel
+func SetVerbosity(level int) {
+	std.SetVerbosity(level)
+	setChannelVerbosity(level)
+}
+
+// Verbosity returns the verbosity level of the package-level debugger
+func Verbosity() int {
+	return std.Verbosity()
+}
+
+// V returns a Verbose which logs only if debugging is enabled at the
+// given verbosity level
+func V(level int) Verbose {
+	return std.v(3, level)
+}
+
 // Output prints message if debug logging is enabled.
 func Output(skip int, msg string) {
 	std.Output(skip, msg)
@@ -195,7 +446,7 @@ func Output(skip int, msg string) {
 
 // Printf prints message if debug logging is enabled.
 func Printf(f string, v ...interface{}) {
-	if !std.Enabled() {
+	if !std.Enabled() && recorder() == nil && !std.selected(2) {
 		return
 	}
 	std.Output(3, fmt.Sprintf(f, v...))
@@ -203,34 +454,44 @@ func Printf(f string, v ...interface{}) {
 
 // Print prints arguments if debug logging is enabled.
 func Print(v ...interface{}) {
-	if !std.Enabled() {
+	if !std.Enabled() && recorder() == nil && !std.selected(2) {
 		return
 	}
 	std.Output(3, fmt.Sprint(v...))
 }
 
-// Assertf will panic if expression is not true, but only if debugging is enabled
+// PrintfCtx calls PrintfCtx on the default debugger
+func PrintfCtx(ctx context.Context, f string, v ...interface{}) {
+	std.printCtx(3, ctx, func() string { return fmt.Sprintf(f, v...) })
+}
+
+// PrintCtx calls PrintCtx on the default debugger
+func PrintCtx(ctx context.Context, v ...interface{}) {
+	std.printCtx(3, ctx, func() string { return fmt.Sprint(v...) })
+}
+
+// Assertf handles a false expression according to the assertion policy;
+// by default it will panic, but only if debugging is enabled
 func Assertf(expr bool, f string, v ...interface{}) {
-	if !std.Enabled() {
+	if expr || std.ignored() {
 		return
 	}
-	if !expr {
-		msg := fmt.Sprintf("ASSERTION FAILED: "+f, v...)
-		std.Output(3, msg)
-		panic(msg)
-	}
+	std.assertFailed(2, fmt.Sprintf("ASSERTION FAILED: "+f, v...))
 }
 
-// Assert will panic if expression is not true, but only if debugging is enabled
+// Assert handles a false expression according to the assertion policy;
+// by default it will panic, but only if debugging is enabled
 func Assert(expr bool, v ...interface{}) {
-	if !std.Enabled() {
+	if expr || std.ignored() {
 		return
 	}
-	if !expr {
-		msg := fmt.Sprintf("ASSERTION FAILED: %s", fmt.Sprint(v...))
-		std.Output(3, msg)
-		panic(msg)
-	}
+	std.assertFailed(2, fmt.Sprintf("ASSERTION FAILED: %s", fmt.Sprint(v...)))
+}
+
+// DumpGoroutines writes the stacks of every goroutine to the debug
+// output, if debugging is enabled
+func DumpGoroutines() {
+	std.dumpGoroutines(3)
 }
 
 // Shell runs command only in debug mode.
@@ -16,6 +16,8 @@ import (
 	"sync"
 	"sync/atomic"
 	"testing"
+
+	"github.com/whamcloud/logging/debug"
 )
 
 type boolStruct struct {
@@ -56,3 +58,24 @@ func BenchmarkAtomicBool(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkDisabledChannel(b *testing.B) {
+	d := debug.Channel("bench.disabled")
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			d.Print("not logged")
+		}
+	})
+}
+
+func BenchmarkVmoduleDisabledCallsite(b *testing.B) {
+	d := debug.NewDebugger(nil)
+	d.SetVmodule("nomatch.go=3")
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			d.Print("not logged")
+		}
+	})
+}
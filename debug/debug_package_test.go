@@ -6,12 +6,17 @@ package debug_test
 
 import (
 	"bytes"
+	"fmt"
 	"log"
 	"os"
+	"os/exec"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/whamcloud/logging/alert"
 	"github.com/whamcloud/logging/debug"
+	"github.com/whamcloud/logging/exittest"
 )
 
 func TestPackageDisable(t *testing.T) {
@@ -110,3 +115,183 @@ func TestPackageWriterWithLogger(t *testing.T) {
 		t.Fatalf("prefixes wrong: %s", lines[1])
 	}
 }
+
+func TestChannels(t *testing.T) {
+	var buf bytes.Buffer
+	debug.SetOutput(&buf)
+	defer debug.SetOutput(os.Stderr)
+	defer debug.EnableChannels("false")
+
+	router := debug.Channel("lnet.router")
+	noisy := debug.Channel("lnet.noisy")
+	other := debug.Channel("other")
+
+	if err := debug.EnableChannels("lnet.*,-lnet.noisy"); err != nil {
+		t.Fatal(err)
+	}
+	if debug.Channel("lnet.router") != router {
+		t.Fatal("expected Channel() to return the same debugger")
+	}
+	if !router.Enabled() || noisy.Enabled() || other.Enabled() || debug.Enabled() {
+		t.Fatalf("unexpected channel state: router=%t noisy=%t other=%t default=%t",
+			router.Enabled(), noisy.Enabled(), other.Enabled(), debug.Enabled())
+	}
+
+	// Channels created after the patterns are set should also match
+	peer := debug.Channel("lnet.peer")
+	if !peer.Enabled() {
+		t.Fatal("expected new channel lnet.peer to be enabled")
+	}
+
+	router.Print(testInputs[0])
+	noisy.Print(testInputs[1])
+	other.Print(testInputs[2])
+
+	lines := strings.Split(buf.String(), "\n")
+	lines = lines[:len(lines)-1] // Don't want the empty line
+	if len(lines) != 1 || !strings.HasSuffix(lines[0], testInputs[0]) {
+		t.Fatalf("Expected only %s to be logged (found %q)", testInputs[0], lines)
+	}
+	if !strings.Contains(lines[0], "[lnet.router]") {
+		t.Fatalf("Expected channel name in output: %s", lines[0])
+	}
+
+	if err := debug.EnableChannels(`/^(other|default)$/`); err != nil {
+		t.Fatal(err)
+	}
+	if router.Enabled() || !other.Enabled() || !debug.Enabled() {
+		t.Fatal("expected regex to enable only other and default")
+	}
+
+	if err := debug.EnableChannels("/(/"); err == nil {
+		t.Fatal("expected error for invalid regex")
+	}
+}
+
+func TestEnableChannelsFor(t *testing.T) {
+	var alerts bytes.Buffer
+	alert.SetOutput(&alerts)
+	defer alert.SetOutput(os.Stderr)
+	defer debug.EnableChannels("false")
+
+	if err := debug.EnableChannels("timed.a"); err != nil {
+		t.Fatal(err)
+	}
+	a, b := debug.Channel("timed.a"), debug.Channel("timed.b")
+	debug.Enable()
+
+	if err := debug.EnableChannels("timed.*@50ms"); err != nil {
+		t.Fatal(err)
+	}
+	if !a.Enabled() || !b.Enabled() {
+		t.Fatal("expected both channels to be enabled")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for b.Enabled() {
+		if time.Now().After(deadline) {
+			t.Fatal("channel was not disabled after its time limit")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !a.Enabled() || debug.EnabledChannels() != "timed.a" {
+		t.Fatalf("expected previous channels to be restored, found %q", debug.EnabledChannels())
+	}
+	if !debug.Enabled() {
+		t.Fatal("expected the default debugger to be left enabled")
+	}
+	if !strings.Contains(alerts.String(), `debug channels "timed.*" disabled after 50ms`) {
+		t.Fatalf("expected alert when channels were disabled: %s", alerts.String())
+	}
+
+	if err := debug.EnableChannels("timed.*@forever"); err == nil {
+		t.Fatal("expected error for invalid duration")
+	}
+}
+
+func TestFlightRecorder(t *testing.T) {
+	var buf, alerts bytes.Buffer
+	debug.SetOutput(&buf)
+	defer debug.SetOutput(os.Stderr)
+	alert.SetOutput(&alerts)
+	defer alert.SetOutput(os.Stderr)
+
+	debug.EnableFlightRecorder(3)
+	defer debug.EnableFlightRecorder(0)
+
+	// Nothing is written while disabled, but the last 3 are recorded
+	debug.Print("dropped")
+	debug.Printf("%s", testInputs[0])
+	debug.V(1).Print(testInputs[1])
+	debug.V(2).Print("above verbosity")
+	debug.Channel("flight").Print(testInputs[2])
+	if buf.Len() != 0 {
+		t.Fatalf("unexpected debug output: %s", buf.String())
+	}
+
+	records := debug.FlightRecords()
+	expected := []string{testInputs[0], testInputs[1], "[flight] " + testInputs[2]}
+	if len(records) != len(expected) {
+		t.Fatalf("expected %d records, found %d", len(expected), len(records))
+	}
+	for i, r := range records {
+		if r.Message != expected[i] || !strings.HasSuffix(r.File, "debug_package_test.go") {
+			t.Fatalf("record %d: expected %s from debug_package_test.go, found %s from %s", i, expected[i], r.Message, r.File)
+		}
+	}
+
+	result := exittest.Capture(func() { alert.Fatal("boom") })
+	if !result.Exited {
+		t.Fatal("expected alert.Fatal to exit")
+	}
+	lines := strings.Split(alerts.String(), "\n")
+	lines = lines[:len(lines)-1] // Don't want the empty line
+	if len(lines) != 6 || !strings.HasSuffix(lines[0], "boom") || !strings.HasSuffix(lines[4], "[flight] "+testInputs[2]) {
+		t.Fatalf("expected flight records after the fatal alert, found %q", lines)
+	}
+}
+
+func TestPackageDumpGoroutines(t *testing.T) {
+	var buf bytes.Buffer
+	debug.SetOutput(&buf)
+	defer debug.SetOutput(os.Stderr)
+	debug.Enable()
+	defer debug.Disable()
+
+	debug.DumpGoroutines()
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) < 2 || !strings.Contains(lines[0], "debug_package_test.go:") || !strings.Contains(lines[0], "goroutine dump") {
+		t.Fatalf("unexpected dump header: %q", lines)
+	}
+}
+
+func TestEnableEnvVar(t *testing.T) {
+	if os.Getenv("DEBUG_ENV_TEST") != "" {
+		// Running in the child process; report what init() did, with
+		// the channel created only after the variable was read
+		fmt.Printf("default=%t channel=%t\n", debug.Enabled(), debug.Channel("lnet.router").Enabled())
+		os.Exit(0)
+	}
+
+	for value, expected := range map[string]string{
+		"1":           "default=true channel=true",
+		"true":        "default=true channel=true",
+		"yes":         "default=true channel=true",
+		"on":          "default=true channel=true",
+		"0":           "default=false channel=false",
+		"off":         "default=false channel=false",
+		"lnet.router": "default=false channel=true",
+		"lnet.*":      "default=false channel=true",
+		"-lnet.*":     "default=false channel=false",
+	} {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestEnableEnvVar$")
+		cmd.Env = append(os.Environ(), "DEBUG_ENV_TEST=1", debug.EnableEnvVar+"="+value)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("%s=%s: %s", debug.EnableEnvVar, value, err)
+		}
+		if got := strings.TrimSpace(string(out)); got != expected {
+			t.Fatalf("%s=%s: expected %s, got %q", debug.EnableEnvVar, value, expected, got)
+		}
+	}
+}
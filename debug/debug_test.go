@@ -6,11 +6,16 @@ package debug_test
 
 import (
 	"bytes"
+	"context"
 	"log"
+	"os"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/whamcloud/logging/alert"
 	"github.com/whamcloud/logging/debug"
+	"github.com/whamcloud/logging/record"
 )
 
 var testInputs = map[int]string{
@@ -102,3 +107,227 @@ func TestWriterWithLogger(t *testing.T) {
 		t.Fatalf("prefixes wrong: %s", lines[1])
 	}
 }
+
+func TestVerbosity(t *testing.T) {
+	var buf bytes.Buffer
+	d := debug.NewDebugger(&buf)
+
+	// Disabled debuggers log at no level
+	d.V(0).Print(testInputs[0])
+	d.Enable()
+	d.SetVerbosity(2)
+
+	d.V(1).Print(testInputs[0])
+	d.V(2).Printf("%s", testInputs[1])
+	d.V(3).Print("too verbose")
+	if d.V(3).Enabled() {
+		t.Fatal("expected V(3) to be disabled at verbosity 2")
+	}
+
+	lines := strings.Split(buf.String(), "\n")
+	lines = lines[:len(lines)-1] // Don't want the empty line
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, found %q", lines)
+	}
+	for i, line := range lines {
+		if !strings.HasSuffix(line, testInputs[i]) {
+			t.Fatalf("line %d: expected %s, found %s", i, testInputs[i], line)
+		}
+		if !strings.Contains(line, "debug_test.go:") {
+			t.Fatalf("line %d: expected caller to be the test: %s", i, line)
+		}
+	}
+}
+
+func TestFlagVerbosity(t *testing.T) {
+	defer debug.EnableChannels("false")
+	defer debug.SetVerbosity(debug.DefaultVerbosity)
+
+	f, _, _ := debug.FlagVar()
+	if err := f.Set("3"); err != nil {
+		t.Fatal(err)
+	}
+	if !debug.Enabled() || debug.Verbosity() != 3 || !debug.V(3).Enabled() || debug.V(4).Enabled() {
+		t.Fatalf("expected debugging at verbosity 3, found %d", debug.Verbosity())
+	}
+	if debug.Channel("verbosity.test").Verbosity() != 3 {
+		t.Fatal("expected new channel to inherit verbosity")
+	}
+
+	if err := f.Set("0"); err != nil {
+		t.Fatal(err)
+	}
+	if debug.Enabled() {
+		t.Fatal("expected -debug=0 to disable debugging")
+	}
+}
+
+func TestVmodule(t *testing.T) {
+	var buf bytes.Buffer
+	d := debug.NewDebugger(&buf)
+
+	// Matching callsites log even though the debugger is disabled
+	if err := d.SetVmodule("nomatch.go=3,debug_test.go=1"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		d.Print(testInputs[0])
+	}
+	d.V(1).Print(testInputs[1])
+	d.V(2).Print("too verbose")
+
+	// Function names may also be matched
+	if err := d.SetVmodule("debug_test.TestVmodule=0"); err != nil {
+		t.Fatal(err)
+	}
+	d.Printf("%s", testInputs[2])
+	d.V(1).Print("too verbose")
+
+	if err := d.SetVmodule("other/*=3"); err != nil {
+		t.Fatal(err)
+	}
+	d.Print("not matched")
+
+	lines := strings.Split(buf.String(), "\n")
+	lines = lines[:len(lines)-1] // Don't want the empty line
+	expected := []string{testInputs[0], testInputs[0], testInputs[1], testInputs[2]}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %q, found %q", expected, lines)
+	}
+	for i, line := range lines {
+		if !strings.HasSuffix(line, expected[i]) || !strings.Contains(line, "debug_test.go:") {
+			t.Fatalf("line %d: expected %s from debug_test.go, found %s", i, expected[i], line)
+		}
+	}
+
+	if err := d.SetVmodule("file.go"); err == nil {
+		t.Fatal("expected error for entry without level")
+	}
+	if d.Vmodule() != "other/*=3" {
+		t.Fatalf("unexpected vmodule after error: %q", d.Vmodule())
+	}
+}
+
+func TestEnableFor(t *testing.T) {
+	var buf, alerts bytes.Buffer
+	alert.SetOutput(&alerts)
+	defer alert.SetOutput(os.Stderr)
+
+	d := debug.NewDebugger(&buf)
+	d.EnableFor(50 * time.Millisecond)
+	if !d.Enabled() {
+		t.Fatal("expected debugger to be enabled")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for d.Enabled() {
+		if time.Now().After(deadline) {
+			t.Fatal("debugger was not disabled after its time limit")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	lines := strings.Split(alerts.String(), "\n")
+	lines = lines[:len(lines)-1] // Don't want the empty line
+	if len(lines) != 2 || !strings.Contains(lines[0], "enabled for 50ms") || !strings.Contains(lines[1], "disabled after 50ms") {
+		t.Fatalf("unexpected alerts: %q", lines)
+	}
+}
+
+func TestAssertPolicy(t *testing.T) {
+	defer debug.SetAssertPolicy(debug.AssertDebug)
+	defer debug.SetAssertRateLimit(debug.DefaultAssertInterval)
+
+	var buf, alerts bytes.Buffer
+	alert.SetOutput(&alerts)
+	defer alert.SetOutput(os.Stderr)
+	d := debug.NewDebugger(&buf)
+
+	// The default policy ignores failures while disabled
+	d.Assert(false, "ignored")
+
+	debug.SetAssertRateLimit(50 * time.Millisecond)
+	debug.SetAssertPolicy(debug.AssertLog)
+	for i := 0; i < 4; i++ {
+		if i == 3 {
+			time.Sleep(60 * time.Millisecond)
+		}
+		d.Assertf(false, "failure %d", i)
+	}
+
+	lines := strings.Split(buf.String(), "\n")
+	lines = lines[:len(lines)-1] // Don't want the empty line
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "ASSERTION FAILED: failure 0") ||
+		!strings.HasSuffix(lines[1], "ASSERTION FAILED: failure 3 (2 more failures suppressed)") {
+		t.Fatalf("unexpected assertion output: %q", lines)
+	}
+	if !strings.Contains(lines[0], "debug_test.go:") {
+		t.Fatalf("expected caller to be the test: %s", lines[0])
+	}
+
+	buf.Reset()
+	debug.SetAssertPolicy(debug.AssertStack)
+	d.Assert(false, "with stack")
+	if !strings.Contains(buf.String(), "with stack\ngoroutine ") {
+		t.Fatalf("expected stack trace: %s", buf.String())
+	}
+
+	debug.SetAssertPolicy(debug.AssertAlert)
+	d.Assert(false, "to alert")
+	if !strings.HasSuffix(alerts.String(), "ASSERTION FAILED: to alert\n") || !strings.Contains(alerts.String(), "debug_test.go:") {
+		t.Fatalf("expected assertion in alert output: %s", alerts.String())
+	}
+
+	debug.SetAssertPolicy(debug.AssertPanic)
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected assertion to panic")
+			}
+		}()
+		d.Assert(false, "panic")
+	}()
+
+	if p, err := debug.ParseAssertPolicy("STACK"); err != nil || p != debug.AssertStack {
+		t.Fatalf("unexpected policy %s (%v)", p, err)
+	}
+}
+
+func TestDumpGoroutines(t *testing.T) {
+	var buf bytes.Buffer
+	d := debug.NewDebugger(&buf)
+
+	d.DumpGoroutines()
+	if buf.Len() != 0 {
+		t.Fatalf("unexpected output while disabled: %s", buf.String())
+	}
+
+	d.Enable()
+	d.DumpGoroutines()
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) < 3 || !strings.Contains(lines[0], "debug_test.go:") || !strings.Contains(lines[0], "goroutine dump") {
+		t.Fatalf("unexpected dump header: %q", lines)
+	}
+	if !strings.HasPrefix(lines[1], "goroutine ") || !strings.Contains(lines[2], "debug_test.TestDumpGoroutines") {
+		t.Fatalf("expected the test goroutine first: %q", lines[1:3])
+	}
+}
+
+func TestPrintCtx(t *testing.T) {
+	var buf bytes.Buffer
+	d := debug.NewDebugger(&buf)
+
+	ctx := record.WithRequestID(context.Background(), "r1")
+	d.PrintCtx(ctx, testInputs[0])
+	d.Enable()
+	d.PrintfCtx(ctx, "%s", testInputs[1])
+
+	lines := strings.Split(buf.String(), "\n")
+	lines = lines[:len(lines)-1] // Don't want the empty line
+	if len(lines) != 1 || !strings.HasSuffix(lines[0], testInputs[1]+" request_id=r1") {
+		t.Fatalf("unexpected output: %q", lines)
+	}
+	if !strings.Contains(lines[0], "debug_test.go:") {
+		t.Fatalf("expected the caller's location, got %q", lines[0])
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package debug
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/whamcloud/logging/alert"
+	"github.com/whamcloud/logging/record"
+)
+
+// FlightRecorderEnvVar is the name of an environment variable that, if
+// set, enables the flight recorder with the given number of records
+const FlightRecorderEnvVar = "DEBUG_FLIGHT_RECORDER"
+
+type (
+	// flightRecorder keeps the most recent debug records in a ring,
+	// whether or not the debuggers which produced them are enabled.
+	// Writers claim a slot with an atomic counter and store the entry
+	// atomically, so recording never takes a lock. Callers are kept
+	// as program counters and only resolved when the ring is dumped.
+	flightRecorder struct {
+		next  uint64
+		slots []atomic.Value // *flightEntry
+	}
+
+	flightEntry struct {
+		seq     uint64
+		time    time.Time
+		channel string
+		pc      uintptr
+		msg     string
+		fields  record.Fields
+	}
+)
+
+var flight atomic.Value // *flightRecorder
+
+// recorder returns the active flight recorder, if any
+func recorder() *flightRecorder {
+	r, _ := flight.Load().(*flightRecorder)
+	return r
+}
+
+func (r *flightRecorder) add(channel string, pc uintptr, msg string, fields record.Fields) {
+	seq := atomic.AddUint64(&r.next, 1)
+	r.slots[(seq-1)%uint64(len(r.slots))].Store(&flightEntry{
+		seq:     seq,
+		time:    time.Now(),
+		channel: channel,
+		pc:      pc,
+		msg:     msg,
+		fields:  fields,
+	})
+}
+
+// entries returns the recorded entries, oldest first. Entries which
+// are overwritten while the ring is being read are skipped.
+func (r *flightRecorder) entries() []*flightEntry {
+	end := atomic.LoadUint64(&r.next)
+	size := uint64(len(r.slots))
+
+	var entries []*flightEntry
+	for i := range r.slots {
+		e, _ := r.slots[i].Load().(*flightEntry)
+		if e == nil || e.seq > end || e.seq+size <= end {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].seq < entries[j].seq
+	})
+
+	return entries
+}
+
+// EnableFlightRecorder keeps the last size debug records in memory,
+// including those from disabled debuggers, so that they can be dumped
+// to the alert output when the process fails. Only plain Print calls
+// and V() calls at or below a debugger's verbosity are recorded. A size
+// of 0 disables the flight recorder and discards its records.
+func EnableFlightRecorder(size int) {
+	if size <= 0 {
+		flight.Store((*flightRecorder)(nil))
+		return
+	}
+	flight.Store(&flightRecorder{slots: make([]atomic.Value, size)})
+}
+
+// FlightRecords returns the records held by the flight recorder,
+// oldest first
+func FlightRecords() []record.Record {
+	r := recorder()
+	if r == nil {
+		return nil
+	}
+
+	entries := r.entries()
+	records := make([]record.Record, len(entries))
+	for i, e := range entries {
+		msg := e.msg
+		if e.channel != "" && e.channel != DefaultChannel {
+			msg = "[" + e.channel + "] " + msg
+		}
+		records[i] = record.Record{
+			Time:     e.time,
+			Stream:   "debug",
+			Severity: record.SeverityDebug,
+			File:     "???",
+			Message:  msg,
+			Fields:   e.fields,
+		}
+		if frame, ok := record.CallerFrame(e.pc); ok {
+			records[i].File, records[i].Line = frame.File, frame.Line
+		}
+	}
+
+	return records
+}
+
+// DumpFlightRecorder writes the flight recorder's records to the alert
+// output. It is called automatically by fatal alerts, applog.Fail and
+// failed debug assertions.
+func DumpFlightRecorder() {
+	records := FlightRecords()
+	if len(records) == 0 {
+		return
+	}
+
+	alert.Warnf("flight recorder: last %d debug records follow", len(records))
+	for i := range records {
+		alert.WriteRecord(&records[i])
+	}
+	alert.Warn("flight recorder: end of debug records")
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package debug
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/whamcloud/logging/record"
+)
+
+// VmoduleEnvVar is the name of an environment variable that, if set,
+// configures a per-file and per-function filter, as accepted by
+// SetVmodule().
+const VmoduleEnvVar = "DEBUG_VMODULE"
+
+type (
+	// vmoduleFilter decides which callsites log, overriding the
+	// debugger's enabled state and verbosity for matching callsites.
+	// Decisions are cached by program counter, so each callsite is
+	// only matched against the patterns once.
+	vmoduleFilter struct {
+		spec     string
+		patterns []vmodulePattern
+		cache    sync.Map // uintptr -> int
+	}
+
+	vmodulePattern struct {
+		glob  string
+		level int
+	}
+
+	// VmoduleFlag allows the flag package to set the vmodule filter
+	VmoduleFlag string
+)
+
+// noMatch is cached for callsites which match no pattern
+const noMatch = -1
+
+// parseVmodule parses a comma-separated list of pattern=level entries.
+// A nil filter is returned for an empty list.
+func parseVmodule(spec string) (*vmoduleFilter, error) {
+	f := &vmoduleFilter{spec: spec}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		i := strings.LastIndexByte(item, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid vmodule entry %q: expected pattern=level", item)
+		}
+		level, err := strconv.Atoi(item[i+1:])
+		if err != nil || level < 0 {
+			return nil, fmt.Errorf("invalid vmodule level in %q", item)
+		}
+		glob := item[:i]
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid vmodule pattern %q: %s", glob, err)
+		}
+		f.patterns = append(f.patterns, vmodulePattern{glob: glob, level: level})
+	}
+
+	if len(f.patterns) == 0 {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// callsiteNames returns the names a pattern may match for a callsite:
+// the file's base name with and without ".go", the file's parent
+// directory and base name (e.g. "pkg/file.go"), the package-qualified
+// function name (e.g. "pkg.(*T).Method") and the bare function name.
+func callsiteNames(pc uintptr) []string {
+	frame, ok := record.CallerFrame(pc)
+	if !ok {
+		return nil
+	}
+
+	base := path.Base(frame.File)
+	names := []string{
+		base,
+		strings.TrimSuffix(base, ".go"),
+		path.Join(path.Base(path.Dir(frame.File)), base),
+	}
+	if fn := frame.Function; fn != "" {
+		fn = fn[strings.LastIndexByte(fn, '/')+1:]
+		names = append(names, fn)
+		if i := strings.IndexByte(fn, '.'); i >= 0 {
+			names = append(names, fn[i+1:])
+		}
+		if i := strings.LastIndexByte(fn, '.'); i >= 0 {
+			names = append(names, fn[i+1:])
+		}
+	}
+
+	return names
+}
+
+// level returns the vmodule level for a callsite, or noMatch. The
+// first matching pattern wins.
+func (f *vmoduleFilter) level(pc uintptr) int {
+	if cached, ok := f.cache.Load(pc); ok {
+		return cached.(int)
+	}
+
+	level := noMatch
+	names := callsiteNames(pc)
+match:
+	for _, p := range f.patterns {
+		for _, name := range names {
+			if matched, _ := path.Match(p.glob, name); matched {
+				level = p.level
+				break match
+			}
+		}
+	}
+
+	f.cache.Store(pc, level)
+	return level
+}
+
+// filter returns the debugger's vmodule filter, if any
+func (d *Debugger) filter() *vmoduleFilter {
+	f, _ := d.vmodule.Load().(*vmoduleFilter)
+	return f
+}
+
+// SetVmodule sets a filter which enables debug output from matching
+// files and functions, e.g. "file.go=2,pkg/*=1,(*Conn).Read=3". Each
+// entry is a glob and a verbosity level; matching callsites log at or
+// below that level whether or not the debugger is enabled, and other
+// callsites are unaffected. An empty spec removes the filter.
+func (d *Debugger) SetVmodule(spec string) error {
+	f, err := parseVmodule(spec)
+	if err != nil {
+		return err
+	}
+	d.vmodule.Store(f)
+	return nil
+}
+
+// Vmodule returns the debugger's vmodule filter specification
+func (d *Debugger) Vmodule() string {
+	if f := d.filter(); f != nil {
+		return f.spec
+	}
+	return ""
+}
+
+// SetVmodule sets the vmodule filter for the package-level debugger and
+// every channel
+func SetVmodule(spec string) error {
+	f, err := parseVmodule(spec)
+	if err != nil {
+		return err
+	}
+	std.vmodule.Store(f)
+	setChannelVmodule(f)
+	return nil
+}
+
+// Vmodule returns the vmodule filter of the package-level debugger
+func Vmodule() string {
+	return std.Vmodule()
+}
+
+// VmoduleFlagVar returns a tuple of parameters suitable for flag.Var()
+func VmoduleFlagVar() (*VmoduleFlag, string, string) {
+	f := VmoduleFlag("")
+	return &f, "debug-vmodule", "comma-separated list of file or function pattern=level settings for debug output"
+}
+
+func (f *VmoduleFlag) String() string {
+	return string(*f)
+}
+
+// Set satisfies the flag.Value interface
+func (f *VmoduleFlag) Set(value string) error {
+	if err := SetVmodule(value); err != nil {
+		return err
+	}
+	*f = VmoduleFlag(value)
+	return nil
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"time"
+
+	"github.com/whamcloud/logging/shutdown"
+)
+
+// RegisterExitHook adds a function to be run by the shutdown pipeline
+// when the program exits through Exit(), alert.Fatal, alert.Abort or
+// applog.Fail. Hooks run before log sinks are flushed and closed.
+func RegisterExitHook(hook func()) {
+	shutdown.RegisterHook(hook)
+}
+
+// SetExitTimeout bounds how long the shutdown pipeline may take, so that
+// a hung sink cannot prevent the program from exiting
+func SetExitTimeout(timeout time.Duration) {
+	shutdown.SetTimeout(timeout)
+}
+
+// SetFatalExitCode sets the exit code used by alert.Fatal, alert.Abort
+// and applog.Fail
+func SetFatalExitCode(code int) {
+	shutdown.SetFatalExitCode(code)
+}
+
+// SetExitHandler sets the package-wide handler called once the shutdown
+// pipeline has run, e.g. shutdown.PanicOnExit to make fatal paths
+// testable. A nil handler restores the default of calling os.Exit().
+func SetExitHandler(handler shutdown.ExitHandler) {
+	shutdown.SetExitHandler(handler)
+}
+
+// Shutdown runs exit hooks and flushes and closes every sink created by
+// CreateWriter, without exiting. It is suitable for deferring in main().
+func Shutdown() {
+	shutdown.Run()
+}
+
+// Exit runs the shutdown pipeline and then exits with the given code
+func Exit(code int) {
+	shutdown.Exit(code)
+}
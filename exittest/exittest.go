@@ -0,0 +1,52 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package exittest helps test code which exits through alert.Fatal,
+// alert.Abort, applog.Fail or logging.Exit.
+package exittest
+
+import (
+	"github.com/whamcloud/logging/shutdown"
+)
+
+type (
+	// Result describes how the function under test finished
+	Result struct {
+		// Exited is true if the function tried to exit
+		Exited bool
+		// Code is the exit code
+		Code int
+		// Message is the message logged by the fatal call, if any
+		Message string
+	}
+)
+
+// Capture runs fn with the package-wide exit handler set to panic, and
+// reports whether fn tried to exit, and with which code and message.
+// The tracked sinks are left open, so logging carries on afterwards,
+// and the previous exit handler is restored. Other panics are passed
+// through. As the exit handler is global, tests using Capture must not
+// run in parallel. Note that loggers with their own exit handler bypass
+// the package-wide handler.
+func Capture(fn func()) (result Result) {
+	defer shutdown.SetExitHandler(shutdown.SetExitHandler(shutdown.PanicOnExit))
+	defer shutdown.SetKeepSinks(shutdown.SetKeepSinks(true))
+
+	defer func() {
+		if r := recover(); r != nil {
+			exit, ok := r.(*shutdown.ExitPanic)
+			if !ok {
+				panic(r)
+			}
+			result = Result{
+				Exited:  true,
+				Code:    exit.Code,
+				Message: exit.Message,
+			}
+		}
+	}()
+
+	fn()
+	return
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package exittest_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/whamcloud/logging"
+	"github.com/whamcloud/logging/alert"
+	"github.com/whamcloud/logging/exittest"
+	"github.com/whamcloud/logging/shutdown"
+)
+
+func TestCaptureFatal(t *testing.T) {
+	var buf bytes.Buffer
+	alert.SetOutput(&buf)
+
+	result := exittest.Capture(func() {
+		alert.Fatalf("cannot continue: %d", 42)
+		t.Fatal("Fatalf returned")
+	})
+
+	if !result.Exited || result.Code != 1 || result.Message != "cannot continue: 42" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if !strings.HasSuffix(buf.String(), "cannot continue: 42\n") {
+		t.Fatalf("message was not logged: %q", buf.String())
+	}
+}
+
+func TestCaptureAbortExitCode(t *testing.T) {
+	alert.SetOutput(&bytes.Buffer{})
+	logging.SetFatalExitCode(3)
+	defer logging.SetFatalExitCode(1)
+
+	result := exittest.Capture(func() {
+		alert.Abort(errors.New("broken"))
+	})
+
+	if !result.Exited || result.Code != 3 || !strings.HasSuffix(result.Message, "broken") {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestCaptureKeepsSinks(t *testing.T) {
+	f, err := ioutil.TempFile("", "logtest-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	w, err := logging.CreateWriter(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		shutdown.Untrack(w.(io.Closer))
+		w.(io.Closer).Close()
+	}()
+	alert.SetOutput(w)
+	defer alert.SetOutput(os.Stderr)
+
+	exittest.Capture(func() {
+		alert.Abort(errors.New("broken"))
+	})
+	alert.Warn("still logging")
+
+	data, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(data), "\n")
+	lines = lines[:len(lines)-1] // Don't want the empty line
+	last := lines[len(lines)-1]
+	if !strings.HasSuffix(last, "still logging") {
+		t.Fatalf("log line after Capture was lost: %q", lines)
+	}
+	if !strings.Contains(last, "exittest_test.go:") {
+		t.Fatalf("Abort did not restore the caller flags: %q", last)
+	}
+}
+
+func TestCaptureRestoresHandler(t *testing.T) {
+	var called bool
+	prev := shutdown.SetExitHandler(func(int, string) { called = true })
+	defer shutdown.SetExitHandler(prev)
+
+	exittest.Capture(func() {
+		logging.Exit(0)
+	})
+	logging.Exit(0)
+	if !called {
+		t.Fatal("the test's exit handler was not restored")
+	}
+}
+
+func TestCaptureNoExit(t *testing.T) {
+	result := exittest.Capture(func() {})
+	if result.Exited {
+		t.Fatalf("unexpected exit: %+v", result)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package external
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type (
+	// Level is the level of a line of 3rd-party output, as detected by
+	// a Classifier
+	Level int
+
+	// Matcher returns the level indicated by a line of output, or
+	// LevelUnknown if it doesn't recognize the line's format
+	Matcher func(line string) Level
+
+	// Classifier detects the level of lines of 3rd-party output using
+	// a list of Matchers. The first matcher to recognize a line wins.
+	Classifier struct {
+		matchers []Matcher
+	}
+)
+
+const (
+	// LevelUnknown means that no level was detected
+	LevelUnknown Level = iota
+	// LevelTrace is for very verbose output
+	LevelTrace
+	// LevelDebug is for debugging output
+	LevelDebug
+	// LevelInfo is for informational output
+	LevelInfo
+	// LevelWarn is for warnings
+	LevelWarn
+	// LevelError is for errors
+	LevelError
+	// LevelFatal is for errors which the 3rd-party code could not
+	// recover from
+	LevelFatal
+)
+
+var levelNames = map[string]Level{
+	"trace":    LevelTrace,
+	"debug":    LevelDebug,
+	"dbg":      LevelDebug,
+	"info":     LevelInfo,
+	"notice":   LevelInfo,
+	"warn":     LevelWarn,
+	"warning":  LevelWarn,
+	"error":    LevelError,
+	"err":      LevelError,
+	"fatal":    LevelFatal,
+	"panic":    LevelFatal,
+	"critical": LevelFatal,
+	"crit":     LevelFatal,
+}
+
+func (l Level) String() string {
+	switch l {
+	case LevelTrace:
+		return "TRACE"
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	case LevelFatal:
+		return "FATAL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// ParseLevel returns the level with the given name, ignoring case, e.g.
+// "warn", "WARNING" or "Err"
+func ParseLevel(name string) (Level, error) {
+	if level, ok := levelNames[strings.ToLower(name)]; ok {
+		return level, nil
+	}
+	return LevelUnknown, fmt.Errorf("unknown level: %s", name)
+}
+
+var (
+	bracketRe = regexp.MustCompile(`\[([A-Za-z]+)\]`)
+	logfmtRe  = regexp.MustCompile(`(?:^|\s)(?:level|lvl)="?([A-Za-z]+)`)
+	jsonRe    = regexp.MustCompile(`"(?:level|lvl|severity)"\s*:\s*"([A-Za-z]+)"`)
+	glogRe    = regexp.MustCompile(`^([IWEF])\d{4} `)
+)
+
+// MatchBrackets recognizes a level name in square brackets, e.g. "[ERROR]"
+func MatchBrackets(line string) Level {
+	for _, m := range bracketRe.FindAllStringSubmatch(line, -1) {
+		if level, err := ParseLevel(m[1]); err == nil {
+			return level
+		}
+	}
+	return LevelUnknown
+}
+
+// MatchLogfmt recognizes a logfmt level key, e.g. "level=warn"
+func MatchLogfmt(line string) Level {
+	return matchFirst(logfmtRe, line)
+}
+
+// MatchJSON recognizes a JSON level key, e.g. `"level":"error"`
+func MatchJSON(line string) Level {
+	return matchFirst(jsonRe, line)
+}
+
+// MatchGlog recognizes the severity character which begins glog and
+// klog lines, e.g. "W0102 15:04:05.000000 ..."
+func MatchGlog(line string) Level {
+	m := glogRe.FindStringSubmatch(line)
+	if m == nil {
+		return LevelUnknown
+	}
+	switch m[1] {
+	case "I":
+		return LevelInfo
+	case "W":
+		return LevelWarn
+	case "E":
+		return LevelError
+	default:
+		return LevelFatal
+	}
+}
+
+// MatchPattern returns a Matcher for a vendor-specific format, using a
+// regular expression whose first subexpression captures a level name
+// accepted by ParseLevel, e.g. `^<(\w+)>`
+func MatchPattern(pattern string) (Matcher, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid level pattern %q: %s", pattern, err)
+	}
+	if re.NumSubexp() < 1 {
+		return nil, fmt.Errorf("level pattern %q has no subexpression", pattern)
+	}
+	return func(line string) Level {
+		return matchFirst(re, line)
+	}, nil
+}
+
+func matchFirst(re *regexp.Regexp, line string) Level {
+	m := re.FindStringSubmatch(line)
+	if m == nil {
+		return LevelUnknown
+	}
+	level, _ := ParseLevel(m[1])
+	return level
+}
+
+// DefaultMatchers recognize the most common level markers
+var DefaultMatchers = []Matcher{MatchJSON, MatchLogfmt, MatchGlog, MatchBrackets}
+
+// NewClassifier returns a *Classifier which tries the supplied matchers
+// before DefaultMatchers
+func NewClassifier(matchers ...Matcher) *Classifier {
+	return &Classifier{
+		matchers: append(matchers[:len(matchers):len(matchers)], DefaultMatchers...),
+	}
+}
+
+// Classify returns the level detected in the line, or LevelUnknown
+func (c *Classifier) Classify(line string) Level {
+	for _, match := range c.matchers {
+		if level := match(line); level != LevelUnknown {
+			return level
+		}
+	}
+	return LevelUnknown
+}
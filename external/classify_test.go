@@ -0,0 +1,62 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package external_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/whamcloud/logging/external"
+)
+
+func TestClassify(t *testing.T) {
+	vendor, err := external.MatchPattern(`^<(\w+)>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := external.NewClassifier(vendor)
+
+	tests := map[string]external.Level{
+		"2021/01/02 [ERROR] connection refused":           external.LevelError,
+		"[main] [Warning] retrying":                       external.LevelWarn,
+		`time=now level=debug msg="dialing"`:              external.LevelDebug,
+		`ts=now lvl="info" msg=ok`:                        external.LevelInfo,
+		`{"level":"fatal","msg":"giving up"}`:             external.LevelFatal,
+		`{"severity": "WARNING", "message": "slow"}`:      external.LevelWarn,
+		"W0102 15:04:05.000000   123 reflector.go:1] hmm": external.LevelWarn,
+		"E0102 15:04:05.000000   123 reflector.go:1] bad": external.LevelError,
+		"<trace> vendor message":                          external.LevelTrace,
+		"[component] nothing to see":                      external.LevelUnknown,
+		"novel=level=warn":                                external.LevelUnknown,
+	}
+	for line, expected := range tests {
+		if level := c.Classify(line); level != expected {
+			t.Errorf("%q: expected %s, got %s", line, expected, level)
+		}
+	}
+
+	if _, err := external.MatchPattern(`^<\w+>`); err == nil {
+		t.Fatal("expected an error for a pattern without a subexpression")
+	}
+}
+
+func TestWriterClassifier(t *testing.T) {
+	var out, errBuf bytes.Buffer
+	w := external.NewWriter(&out).Classifier(external.NewClassifier(), map[external.Level]io.Writer{
+		external.LevelError: &errBuf,
+	}).Prefix("lib: ")
+
+	w.Write([]byte("[INFO] line1"))
+	w.Log("[ERROR] line2")
+
+	if out.String() != "lib: [INFO] line1\n" {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+	if !strings.HasSuffix(errBuf.String(), "lib: [ERROR] line2\n") {
+		t.Fatalf("error was not routed: %q", errBuf.String())
+	}
+}
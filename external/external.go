@@ -8,13 +8,17 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"time"
 )
 
 type (
 	// Writer is an optionally-prefixed writer for
 	// 3rd-party logging packages.
 	Writer struct {
-		log *log.Logger
+		log        *log.Logger
+		classifier *Classifier
+		routes     map[Level]*log.Logger
+		lines      *LineBuffer
 	}
 )
 
@@ -34,16 +38,76 @@ func (w *Writer) SetOutput(out io.Writer) {
 // Prefix optionally sets a logging prefix for the writer
 func (w *Writer) Prefix(prefix string) *Writer {
 	w.log.SetPrefix(prefix)
+	for _, l := range w.routes {
+		l.SetPrefix(prefix)
+	}
+	return w
+}
+
+// Classifier optionally routes lines to other writers by their level,
+// e.g. sending a library's errors to alert.Writer(). Lines at a level
+// without a route are written to the writer's own output.
+func (w *Writer) Classifier(c *Classifier, routes map[Level]io.Writer) *Writer {
+	w.classifier = c
+	w.routes = make(map[Level]*log.Logger, len(routes))
+	for level, out := range routes {
+		w.routes[level] = log.New(out, w.log.Prefix(), 0)
+	}
+	return w
+}
+
+// logger returns the logger for the line's level
+func (w *Writer) logger(line string) *log.Logger {
+	if w.classifier == nil {
+		return w.log
+	}
+	if l, ok := w.routes[w.classifier.Classify(line)]; ok {
+		return l
+	}
+	return w.log
+}
+
+// Lines optionally buffers the data written until it forms complete
+// lines, which are logged one at a time. Lines longer than maxLen are
+// split, and a partial line is logged after timeout or by Close. A
+// zero maxLen or timeout disables the limit.
+func (w *Writer) Lines(maxLen int, timeout time.Duration) *Writer {
+	w.lines = NewLineBuffer(maxLen, timeout, func(line string) {
+		w.logger(line).Output(2, line)
+	})
 	return w
 }
 
 func (w *Writer) Write(data []byte) (int, error) {
-	w.log.Output(3, string(data))
+	if w.lines == nil {
+		msg := string(data)
+		w.logger(msg).Output(3, msg)
+		return len(data), nil
+	}
 
+	w.lines.LockEmit()
+	defer w.lines.UnlockEmit()
+	for _, line := range w.lines.Lines(data) {
+		w.logger(line).Output(3, line)
+	}
 	return len(data), nil
 }
 
+// Close logs any partial line held by a line-buffered writer
+func (w *Writer) Close() error {
+	if w.lines == nil {
+		return nil
+	}
+	w.lines.LockEmit()
+	defer w.lines.UnlockEmit()
+	if line := w.lines.Flush(); line != "" {
+		w.logger(line).Output(3, line)
+	}
+	return nil
+}
+
 // Log implements the aws.Logger interface, among others
 func (w *Writer) Log(v ...interface{}) {
-	w.log.Output(3, fmt.Sprint(v...))
+	msg := fmt.Sprint(v...)
+	w.logger(msg).Output(3, msg)
 }
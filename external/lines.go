@@ -0,0 +1,158 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package external
+
+import (
+	"bytes"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+type (
+	// LineBuffer reassembles partial writes into lines, and splits
+	// writes which contain several lines, for writers whose callers
+	// don't write one line at a time
+	LineBuffer struct {
+		emit    sync.Mutex
+		mu      sync.Mutex
+		buf     []byte
+		maxLen  int
+		timeout time.Duration
+		timer   *time.Timer
+		gen     uint64
+		expired func(line string)
+	}
+)
+
+const (
+	// DefaultMaxLineLength is a reasonable cap on the length of lines
+	// held by a LineBuffer
+	DefaultMaxLineLength = 64 * 1024
+
+	// DefaultLineTimeout is a reasonable time to wait for the rest of
+	// a partial line
+	DefaultLineTimeout = time.Second
+)
+
+// NewLineBuffer returns a *LineBuffer. Lines longer than maxLen bytes
+// are split, and a partial line which isn't completed within timeout is
+// passed to expired. A zero maxLen or timeout disables the limit.
+func NewLineBuffer(maxLen int, timeout time.Duration, expired func(line string)) *LineBuffer {
+	return &LineBuffer{
+		maxLen:  maxLen,
+		timeout: timeout,
+		expired: expired,
+	}
+}
+
+// Lines appends data to the buffer and returns the complete lines,
+// without their line endings. Empty lines are dropped.
+func (b *LineBuffer) Lines(data []byte) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buf = append(b.buf, data...)
+
+	var lines []string
+	start := 0
+	for {
+		rest := b.buf[start:]
+		i := bytes.IndexByte(rest, '\n')
+		n := len(rest)
+		if i >= 0 {
+			n = i
+		}
+		if b.maxLen > 0 && n > b.maxLen {
+			n = b.splitAt(rest)
+			lines = append(lines, string(rest[:n]))
+			start += n
+			continue
+		}
+		if i < 0 {
+			break
+		}
+		if line := bytes.TrimSuffix(rest[:i], []byte("\r")); len(line) > 0 {
+			lines = append(lines, string(line))
+		}
+		start += i + 1
+	}
+	b.buf = b.buf[:copy(b.buf, b.buf[start:])]
+
+	b.gen++
+	b.resetTimer()
+	return lines
+}
+
+// splitAt returns where to split an overlong line, avoiding splitting
+// a UTF-8 sequence
+func (b *LineBuffer) splitAt(line []byte) int {
+	for n := b.maxLen; n > 0; n-- {
+		if utf8.RuneStart(line[n]) {
+			return n
+		}
+	}
+	return b.maxLen
+}
+
+// resetTimer must be called with the lock held
+func (b *LineBuffer) resetTimer() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.buf) == 0 || b.timeout <= 0 || b.expired == nil {
+		return
+	}
+
+	gen := b.gen
+	b.timer = time.AfterFunc(b.timeout, func() {
+		b.emit.Lock()
+		defer b.emit.Unlock()
+
+		b.mu.Lock()
+		if gen != b.gen {
+			// More data arrived after the timer fired
+			b.mu.Unlock()
+			return
+		}
+		line := b.take()
+		b.mu.Unlock()
+
+		if line != "" {
+			b.expired(line)
+		}
+	})
+}
+
+// LockEmit should be held while emitting the lines returned by Lines or
+// Flush. Expired lines are emitted with it held, so that they are never
+// emitted after the lines of a later write.
+func (b *LineBuffer) LockEmit() {
+	b.emit.Lock()
+}
+
+// UnlockEmit releases the lock taken by LockEmit
+func (b *LineBuffer) UnlockEmit() {
+	b.emit.Unlock()
+}
+
+// take must be called with the lock held
+func (b *LineBuffer) take() string {
+	line := string(bytes.TrimSuffix(b.buf, []byte("\r")))
+	b.buf = b.buf[:0]
+	b.gen++
+	return line
+}
+
+// Flush returns any partial line held by the buffer and empties it
+func (b *LineBuffer) Flush() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	line := b.take()
+	b.resetTimer()
+	return line
+}
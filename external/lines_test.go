@@ -0,0 +1,111 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package external_test
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/whamcloud/logging/external"
+)
+
+// syncBuffer allows the output of a timed flush to be read safely
+type syncBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(data []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.Write(data)
+}
+
+func (b *syncBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.String()
+}
+
+func TestWriterLines(t *testing.T) {
+	var buf bytes.Buffer
+	w := external.NewWriter(&buf).Prefix("lib: ").Lines(8, 0)
+
+	w.Write([]byte("li"))
+	w.Write([]byte("ne1\r\nline2\n\nline3"))
+	w.Write([]byte("\n0123456789abc"))
+	w.Write([]byte("d\npartial"))
+	if strings.Contains(buf.String(), "partial") {
+		t.Fatalf("partial line was logged early: %q", buf.String())
+	}
+	w.Close()
+
+	expected := []string{"line1", "line2", "line3", "01234567", "89abcd", "partial"}
+	lines := strings.Split(buf.String(), "\n")
+	lines = lines[:len(lines)-1] // Don't want the empty line
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %q", len(expected), lines)
+	}
+	for i, line := range lines {
+		if line != "lib: "+expected[i] {
+			t.Fatalf("line %d: expected %s, found %s", i, expected[i], line)
+		}
+	}
+}
+
+func TestLineBufferUTF8(t *testing.T) {
+	b := external.NewLineBuffer(4, 0, nil)
+
+	lines := b.Lines([]byte("aaé€\naaa€\n"))
+	expected := []string{"aaé", "€", "aaa", "€"}
+	if strings.Join(lines, "|") != strings.Join(expected, "|") {
+		t.Fatalf("expected %q, got %q", expected, lines)
+	}
+}
+
+func TestWriterLineTimeout(t *testing.T) {
+	var buf syncBuffer
+	w := external.NewWriter(&buf).Lines(0, 10*time.Millisecond)
+
+	w.Write([]byte("partial"))
+	deadline := time.Now().Add(5 * time.Second)
+	for buf.String() == "" && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if buf.String() != "partial\n" {
+		t.Fatalf("partial line was not flushed: %q", buf.String())
+	}
+
+	w.Write([]byte("line\n"))
+	w.Close()
+	if buf.String() != "partial\nline\n" {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+}
+
+func TestLineBufferEmitOrder(t *testing.T) {
+	var expired []string
+	b := external.NewLineBuffer(0, time.Millisecond, func(line string) {
+		expired = append(expired, line)
+	})
+
+	// A write in progress holds back the expiry of the partial line,
+	// which is completed by the write instead
+	b.Lines([]byte("part"))
+	b.LockEmit()
+	time.Sleep(20 * time.Millisecond)
+	lines := b.Lines([]byte("ial\n"))
+	b.UnlockEmit()
+
+	time.Sleep(20 * time.Millisecond)
+	b.LockEmit()
+	defer b.UnlockEmit()
+	if len(lines) != 1 || lines[0] != "partial" || len(expired) != 0 {
+		t.Fatalf("expected the write to complete the line, got %q (expired %q)", lines, expired)
+	}
+}
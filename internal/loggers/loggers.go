@@ -0,0 +1,46 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package loggers holds the loggers which the adapter and sloghandler
+// packages route messages to, falling back to the package-level loggers
+// for any which aren't set.
+package loggers
+
+import (
+	"github.com/whamcloud/logging/alert"
+	"github.com/whamcloud/logging/applog"
+	"github.com/whamcloud/logging/debug"
+)
+
+// Set holds the loggers for each kind of message. A nil logger selects
+// the package-level one.
+type Set struct {
+	Debug *debug.Debugger
+	App   *applog.AppLogger
+	Alert *alert.Logger
+}
+
+// Debugger returns the debugger, or the default channel's debugger
+func (s *Set) Debugger() *debug.Debugger {
+	if s.Debug != nil {
+		return s.Debug
+	}
+	return debug.Channel(debug.DefaultChannel)
+}
+
+// AppLogger returns the applog logger, or the standard one
+func (s *Set) AppLogger() *applog.AppLogger {
+	if s.App != nil {
+		return s.App
+	}
+	return applog.StandardLogger()
+}
+
+// AlertLogger returns the alert logger, or the standard one
+func (s *Set) AlertLogger() *alert.Logger {
+	if s.Alert != nil {
+		return s.Alert
+	}
+	return alert.StandardLogger()
+}
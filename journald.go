@@ -0,0 +1,260 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/whamcloud/logging/record"
+	"github.com/whamcloud/logging/shutdown"
+)
+
+// JournaldSocket is the default path of the journald native protocol socket
+const JournaldSocket = "/run/systemd/journal/socket"
+
+type (
+	// JournaldWriter sends log records to systemd-journald using its
+	// native protocol, so that the caller, priority, stream and any
+	// structured fields are stored as journal fields.
+	JournaldWriter struct {
+		path       string
+		identifier string
+
+		mu     sync.Mutex
+		conn   *net.UnixConn
+		closed bool
+	}
+
+	// JournaldOpt sets JournaldWriter options
+	JournaldOpt func(*JournaldWriter)
+)
+
+// JournaldIdentifier sets SYSLOG_IDENTIFIER, which defaults to the
+// program name
+func JournaldIdentifier(identifier string) JournaldOpt {
+	return func(w *JournaldWriter) {
+		w.identifier = identifier
+	}
+}
+
+// NewJournaldWriter returns a *JournaldWriter which sends to the
+// journald socket at path (JournaldSocket if empty).
+func NewJournaldWriter(path string, options ...JournaldOpt) (*JournaldWriter, error) {
+	if path == "" {
+		path = JournaldSocket
+	}
+	w := &JournaldWriter{
+		path:       path,
+		identifier: progName(),
+	}
+
+	for _, option := range options {
+		option(w)
+	}
+
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// connect must be called with the lock held
+func (w *JournaldWriter) connect() error {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: w.path, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	w.conn = conn
+	return nil
+}
+
+// journaldReserved are the journal fields set by the writer itself, or
+// with a meaning to journald, which record fields may not use
+var journaldReserved = map[string]bool{
+	"MESSAGE":    true,
+	"MESSAGE_ID": true,
+	"PRIORITY":   true,
+	"ERRNO":      true,
+	"STREAM":     true,
+	"LEVEL":      true,
+	"STACK":      true,
+}
+
+// journaldFieldName converts a field key into a valid journal field
+// name: upper case letters, digits and underscores, not starting with
+// an underscore or digit. Reserved names are prefixed with "FIELD_".
+func journaldFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+	name = strings.TrimLeft(name, "_0123456789")
+	if name == "" {
+		return "FIELD"
+	}
+	if journaldReserved[name] || strings.HasPrefix(name, "CODE_") || strings.HasPrefix(name, "SYSLOG_") {
+		// Don't let record fields override the writer's own
+		return "FIELD_" + name
+	}
+	return name
+}
+
+func appendJournaldField(buf *bytes.Buffer, name, value string) {
+	if !strings.ContainsRune(value, '\n') {
+		buf.WriteString(name)
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+
+	// Values with newlines are sent as NAME\n<64-bit LE length><value>\n
+	buf.WriteString(name)
+	buf.WriteByte('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+func (w *JournaldWriter) datagram(r *record.Record) []byte {
+	var buf bytes.Buffer
+
+	message := r.Message
+	if r.Sealed && len(r.Encoded) > 0 {
+		// Sealed records are sent as signed, so they can be verified
+		message = string(r.Encoded)
+	}
+	appendJournaldField(&buf, "MESSAGE", strings.TrimSuffix(message, "\n"))
+	appendJournaldField(&buf, "PRIORITY", strconv.Itoa(r.Severity.Syslog()))
+	if w.identifier != "" {
+		appendJournaldField(&buf, "SYSLOG_IDENTIFIER", w.identifier)
+	}
+	if r.Stream != "" {
+		appendJournaldField(&buf, "STREAM", r.Stream)
+	}
+	if r.Level != "" {
+		appendJournaldField(&buf, "LEVEL", r.Level)
+	}
+	if r.File != "" {
+		appendJournaldField(&buf, "CODE_FILE", r.File)
+		appendJournaldField(&buf, "CODE_LINE", strconv.Itoa(r.Line))
+	}
+	if r.Stack != "" {
+		appendJournaldField(&buf, "STACK", r.Stack)
+	}
+	for _, field := range r.Fields {
+		appendJournaldField(&buf, journaldFieldName(field.Key), fmt.Sprint(field.Value))
+	}
+
+	return buf.Bytes()
+}
+
+func (w *JournaldWriter) send(data []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return os.ErrClosed
+	}
+	if w.conn == nil {
+		if err := w.connect(); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.conn.Write(data)
+	if err != nil && !isMessageTooLong(err) {
+		// journald may have restarted; reconnect and try once more.
+		if err := w.connect(); err != nil {
+			return err
+		}
+		_, err = w.conn.Write(data)
+	}
+	if err == nil {
+		return nil
+	}
+	if !isMessageTooLong(err) {
+		return err
+	}
+
+	// Datagrams which are too large for the socket are written to an
+	// unlinked temporary file and the descriptor is passed instead.
+	f, err := ioutil.TempFile("/dev/shm", "journal-")
+	if err != nil {
+		if f, err = ioutil.TempFile("", "journal-"); err != nil {
+			return err
+		}
+	}
+	defer f.Close()
+	os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	_, _, err = w.conn.WriteMsgUnix(nil, syscall.UnixRights(int(f.Fd())), nil)
+	return err
+}
+
+func isMessageTooLong(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS)
+}
+
+// WriteRecord implements record.RecordWriter
+func (w *JournaldWriter) WriteRecord(r *record.Record) error {
+	return w.send(w.datagram(r))
+}
+
+// Write implements io.Writer, sending data as a single message at
+// notice severity.
+func (w *JournaldWriter) Write(data []byte) (int, error) {
+	err := w.WriteRecord(&record.Record{
+		Time:     time.Now(),
+		Severity: record.SeverityNotice,
+		Message:  string(data),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Close closes the connection to journald
+func (w *JournaldWriter) Close() error {
+	shutdown.Untrack(w)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package logging_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/whamcloud/logging"
+	"github.com/whamcloud/logging/alert"
+	"github.com/whamcloud/logging/audit"
+	"github.com/whamcloud/logging/debug"
+)
+
+// parseJournald decodes a native protocol datagram into its fields
+func parseJournald(t *testing.T, data []byte) map[string]string {
+	fields := make(map[string]string)
+	for len(data) > 0 {
+		i := bytes.IndexAny(data, "=\n")
+		if i < 0 {
+			t.Fatalf("malformed datagram: %q", data)
+		}
+		name := string(data[:i])
+		if data[i] == '=' {
+			end := bytes.IndexByte(data, '\n')
+			fields[name] = string(data[i+1 : end])
+			data = data[end+1:]
+			continue
+		}
+		size := binary.LittleEndian.Uint64(data[i+1 : i+9])
+		fields[name] = string(data[i+9 : i+9+int(size)])
+		data = data[i+9+int(size)+1:]
+	}
+	return fields
+}
+
+func journaldStandIn(t *testing.T) (*net.UnixConn, string, func()) {
+	dir, err := ioutil.TempDir("", "logtest-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return conn, path, func() {
+		conn.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func readJournald(t *testing.T, conn *net.UnixConn) map[string]string {
+	buf := make([]byte, 65536)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parseJournald(t, buf[:n])
+}
+
+func TestJournaldWriter(t *testing.T) {
+	conn, path, cleanup := journaldStandIn(t)
+	defer cleanup()
+
+	w, err := logging.NewJournaldWriter(path, logging.JournaldIdentifier("logtest"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	a := alert.NewLogger(w)
+	a.Warn("first line\nsecond line")
+
+	fields := readJournald(t, conn)
+	expected := map[string]string{
+		"MESSAGE":           "first line\nsecond line",
+		"PRIORITY":          "4",
+		"SYSLOG_IDENTIFIER": "logtest",
+		"STREAM":            "alert",
+	}
+	for name, value := range expected {
+		if fields[name] != value {
+			t.Fatalf("%s: expected %q, found %q", name, value, fields[name])
+		}
+	}
+	if !strings.HasSuffix(fields["CODE_FILE"], "journald_test.go") || fields["CODE_LINE"] == "" {
+		t.Fatalf("unexpected caller: %s:%s", fields["CODE_FILE"], fields["CODE_LINE"])
+	}
+}
+
+func TestJournaldWriterDebug(t *testing.T) {
+	conn, path, cleanup := journaldStandIn(t)
+	defer cleanup()
+
+	w, err := logging.NewJournaldWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	d := debug.NewDebugger(w)
+	d.Enable()
+	d.Print("debugging")
+
+	fields := readJournald(t, conn)
+	if fields["MESSAGE"] != "debugging" || fields["PRIORITY"] != "7" || fields["STREAM"] != "debug" {
+		t.Fatalf("unexpected fields: %v", fields)
+	}
+}
+
+func TestJournaldWriterReservedFields(t *testing.T) {
+	conn, path, cleanup := journaldStandIn(t)
+	defer cleanup()
+
+	w, err := logging.NewJournaldWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	l := audit.NewLogger(w)
+	l.LogFields("real message", "message", "fake", "priority", 0, "code_file", "x.go", "user", "alice")
+
+	fields := readJournald(t, conn)
+	expected := map[string]string{
+		"MESSAGE":         "real message",
+		"PRIORITY":        "5",
+		"FIELD_MESSAGE":   "fake",
+		"FIELD_PRIORITY":  "0",
+		"FIELD_CODE_FILE": "x.go",
+		"USER":            "alice",
+	}
+	for name, value := range expected {
+		if fields[name] != value {
+			t.Fatalf("%s: expected %q, found %q", name, value, fields[name])
+		}
+	}
+}
+
+func TestJournaldWriterReconnect(t *testing.T) {
+	conn, path, cleanup := journaldStandIn(t)
+	defer cleanup()
+
+	w, err := logging.NewJournaldWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// Simulate journald restarting
+	conn.Close()
+	os.Remove(path)
+	conn, err = net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := w.Write([]byte("after restart")); err != nil {
+		t.Fatal(err)
+	}
+	fields := readJournald(t, conn)
+	if fields["MESSAGE"] != "after restart" {
+		t.Fatalf("unexpected fields: %v", fields)
+	}
+}
@@ -10,9 +10,13 @@ import (
 	"io/ioutil"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/whamcloud/logging/alert"
 	"github.com/whamcloud/logging/audit"
+	"github.com/whamcloud/logging/debug"
+	"github.com/whamcloud/logging/record"
+	"github.com/whamcloud/logging/shutdown"
 )
 
 const (
@@ -23,9 +27,67 @@ const (
 	LogFileMode = 0600
 )
 
+type (
+	// WriterOpt sets options for the writers created by CreateWriter
+	WriterOpt func(*writerOptions)
+
+	writerOptions struct {
+		reopen  bool
+		rotate  bool
+		rotates []RotateOpt
+	}
+)
+
+// setWriterCreated holds the sinks which the last call to SetWriter
+// created from strings, so that they are closed when it is replaced
+var setWriterCreated struct {
+	sync.Mutex
+	writers []io.Writer
+}
+
+// Reopenable makes CreateWriter open file paths as a *ReopenableFile,
+// which is reopened by Reopen(), rather than an *os.File
+func Reopenable() WriterOpt {
+	return func(o *writerOptions) {
+		o.reopen = true
+	}
+}
+
+// Rotate makes CreateWriter open file paths as a *RotatingWriter
+// configured by the supplied options, e.g.
+// Rotate(MaxFileSize(100<<20), MaxBackups(5), CompressBackups(true))
+func Rotate(options ...RotateOpt) WriterOpt {
+	return func(o *writerOptions) {
+		o.rotate = true
+		o.rotates = append(o.rotates, options...)
+	}
+}
+
 // CreateWriter is a convenience function to ensure that the given input
-// results in an io.Writer
-func CreateWriter(w interface{}) (io.Writer, error) {
+// results in an io.Writer. Writers such as a *RotatingWriter are passed
+// through unchanged. Strings may be "stderr", "stdout", "" (discard), a
+// file path, or a sink URL such as:
+//
+//	file:///var/log/foo.log?mode=0640&rotate=100MB&backups=5&compress=true
+//	tcp://host:port, udp://host:port, unix:///path, unixgram:///path
+//	syslog://host:514, syslog+tcp://host:601, syslog:///dev/log
+//	journald://, journald:///run/systemd/journal/socket
+//	multi:stderr,file:///var/log/foo.log
+//
+// Any URL may add "async=<queue size>" and "overflow=<policy>" to its
+// query to wrap the sink in an *AsyncWriter.
+//
+// Additional schemes may be added with RegisterScheme(). File paths are
+// opened as an *os.File, as a *ReopenableFile which can be reopened with
+// Reopen() if the Reopenable() option is given (as SetWriter always
+// does), or as a *RotatingWriter if the Rotate() option is given.
+// Writers created from strings are flushed and closed by Shutdown().
+func CreateWriter(w interface{}, options ...WriterOpt) (io.Writer, error) {
+	var opts writerOptions
+	for _, option := range options {
+		option(&opts)
+	}
+
 	switch w := w.(type) {
 	case io.Writer:
 		return w, nil
@@ -37,23 +99,121 @@ func CreateWriter(w interface{}) (io.Writer, error) {
 			return os.Stdout, nil
 		case "":
 			return ioutil.Discard, nil
+		}
+		var writer io.Writer
+		var err error
+		switch {
+		case isURL(w):
+			writer, err = createURLWriter(w, options)
+		case opts.rotate:
+			writer, err = NewRotatingWriter(w, opts.rotates...)
+		case opts.reopen:
+			writer, err = OpenReopenableFile(w)
 		default:
-			return os.OpenFile(w, LogFileFlags, LogFileMode)
+			writer, err = os.OpenFile(w, LogFileFlags, LogFileMode)
 		}
+		if err != nil {
+			return nil, err
+		}
+		if c, ok := writer.(io.Closer); ok {
+			shutdown.Track(c)
+		}
+		return writer, nil
 	default:
 		return nil, fmt.Errorf("CreateWriter() called with unhandled input: %v", w)
 	}
 }
 
-// SetWriter sets up the writer for non-interactive logging libraries
-func SetWriter(w interface{}) error {
-	writer, err := CreateWriter(w)
-	if err != nil {
-		return err
+// SetWriter sets up the writer for non-interactive logging libraries.
+// If more than one writer is given, output is sent to all of them
+// through a *MultiWriter. Any WriterOpt arguments, e.g. Rotate(), are
+// passed to CreateWriter for every writer. File paths are always opened
+// with Reopenable(), so that Reopen() picks them up after logrotate.
+// The sinks created from strings by the previous call are closed.
+func SetWriter(w ...interface{}) error {
+	var specs []interface{}
+	options := []WriterOpt{Reopenable()}
+	for _, arg := range w {
+		if option, ok := arg.(WriterOpt); ok {
+			options = append(options, option)
+		} else {
+			specs = append(specs, arg)
+		}
+	}
+
+	var writer io.Writer
+	var created []io.Writer
+	switch len(specs) {
+	case 0:
+		return fmt.Errorf("SetWriter() called without a writer")
+	case 1:
+		var err error
+		if writer, err = CreateWriter(specs[0], options...); err != nil {
+			return err
+		}
+		if _, ok := specs[0].(io.Writer); !ok {
+			created = append(created, writer)
+		}
+	default:
+		m := NewMultiWriter()
+		for _, spec := range specs {
+			sink, err := CreateWriter(spec, options...)
+			if err != nil {
+				closeCreated(created)
+				return err
+			}
+			if _, ok := spec.(io.Writer); !ok {
+				created = append(created, sink)
+			}
+			m.Add(sink)
+		}
+		writer = m
 	}
 
+	setWriterCreated.Lock()
+	defer setWriterCreated.Unlock()
 	audit.SetOutput(writer)
 	alert.SetOutput(writer)
+	closeCreated(setWriterCreated.writers)
+	setWriterCreated.writers = created
+
+	return nil
+}
+
+// closeCreated closes writers which CreateWriter created from strings,
+// leaving the standard streams open
+func closeCreated(writers []io.Writer) {
+	for _, w := range writers {
+		if w == os.Stdout || w == os.Stderr {
+			continue
+		}
+		if c, ok := w.(io.Closer); ok {
+			shutdown.Untrack(c)
+			c.Close()
+		}
+	}
+}
+
+// SetEncoder sets the output encoding for non-interactive logging
+// libraries, e.g. record.JSON for JSON Lines output. The encoder may
+// also be given by name ("text" or "json").
+func SetEncoder(e interface{}) error {
+	var encoder record.Encoder
+	switch e := e.(type) {
+	case record.Encoder:
+		encoder = e
+	case string:
+		var err error
+		if encoder, err = record.ParseEncoder(e); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("SetEncoder() called with unhandled input: %v", e)
+	}
+
+	audit.SetEncoder(encoder)
+	alert.SetEncoder(encoder)
+	debug.SetEncoder(encoder)
 
 	return nil
 }
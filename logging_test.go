@@ -6,7 +6,10 @@ package logging_test
 
 import (
 	"bytes"
+	"encoding/json"
+	"io"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"strings"
 	"testing"
@@ -98,3 +101,65 @@ func TestSetWriter(t *testing.T) {
 		t.Fatalf("audit logging didn't work: %s", lines[1])
 	}
 }
+
+type closeRecorder struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestSetWriterCleanup(t *testing.T) {
+	created := &closeRecorder{}
+	logging.RegisterScheme("cleanup", func(u *url.URL) (io.Writer, error) {
+		return created, nil
+	})
+	given := &closeRecorder{}
+
+	if err := logging.SetWriter("cleanup://sink", given, "nosuch://thing"); err == nil {
+		t.Fatal("expected error for unregistered scheme")
+	}
+	if !created.closed {
+		t.Fatal("sink created before the failure was not closed")
+	}
+	if given.closed {
+		t.Fatal("caller's writer should not be closed")
+	}
+}
+
+func TestSetEncoder(t *testing.T) {
+	var buf bytes.Buffer
+
+	logging.SetWriter(&buf)
+	if err := logging.SetEncoder("json"); err != nil {
+		t.Fatal(err)
+	}
+	defer logging.SetEncoder("text")
+
+	alert.Warn("this is an alert!")
+	audit.LogFields("no big deal", "user", "alice")
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, found: %q", lines)
+	}
+	for i, stream := range []string{"alert", "audit"} {
+		var decoded map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[i]), &decoded); err != nil {
+			t.Fatalf("line %d is not JSON: %s", i, err)
+		}
+		if decoded["stream"] != stream {
+			t.Fatalf("line %d: expected stream %s, found %v", i, stream, decoded["stream"])
+		}
+		if caller, _ := decoded["caller"].(string); !strings.Contains(caller, "logging_test.go:") {
+			t.Fatalf("line %d: unexpected caller %v", i, decoded["caller"])
+		}
+	}
+
+	if err := logging.SetEncoder("xml"); err == nil {
+		t.Fatal("expected error for unknown encoder")
+	}
+}
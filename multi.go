@@ -0,0 +1,203 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/whamcloud/logging/record"
+	"github.com/whamcloud/logging/shutdown"
+)
+
+// DefaultSinkRetryInterval is how long a failed sink is skipped before
+// a MultiWriter tries writing to it again
+const DefaultSinkRetryInterval = time.Minute
+
+// ErrAllSinksFailed is returned by a MultiWriter when no sink accepted
+// the write
+var ErrAllSinksFailed = errors.New("all log sinks failed")
+
+type (
+	// SinkErrorHandler is called when a sink fails (with the error that
+	// caused it to be isolated) and when it recovers (with a nil error).
+	// It is called with the MultiWriter's lock held, so must not write
+	// to the MultiWriter.
+	SinkErrorHandler func(sink io.Writer, err error)
+
+	// MultiWriter writes to several sinks, e.g. a file and syslog. Unlike
+	// io.MultiWriter, a sink which fails is reported once and isolated,
+	// so that output continues to reach the remaining sinks. Isolated
+	// sinks are retried periodically. Records are passed to sinks which
+	// implement record.RecordWriter.
+	MultiWriter struct {
+		mu      sync.Mutex
+		sinks   []*sink
+		onError SinkErrorHandler
+		retry   time.Duration
+	}
+
+	sink struct {
+		w        io.Writer
+		failedAt time.Time
+	}
+)
+
+// NewMultiWriter returns a *MultiWriter for the supplied sinks. Sink
+// failures are reported on stderr unless OnError() is used to set a
+// handler.
+func NewMultiWriter(writers ...io.Writer) *MultiWriter {
+	m := &MultiWriter{
+		onError: reportSinkError,
+		retry:   DefaultSinkRetryInterval,
+	}
+	for _, w := range writers {
+		m.Add(w)
+	}
+
+	return m
+}
+
+func reportSinkError(w io.Writer, err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "logging: disabling %T sink after error: %s\n", w, err)
+	}
+}
+
+// OnError sets the handler for sink failures and recoveries
+func (m *MultiWriter) OnError(handler SinkErrorHandler) *MultiWriter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onError = handler
+	return m
+}
+
+// RetryInterval sets how long a failed sink is skipped before retrying
+func (m *MultiWriter) RetryInterval(interval time.Duration) *MultiWriter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retry = interval
+	return m
+}
+
+// Add adds a sink
+func (m *MultiWriter) Add(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sinks = append(m.sinks, &sink{w: w})
+}
+
+// Sinks returns the sinks which have not been isolated due to failure
+func (m *MultiWriter) Sinks() []io.Writer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var healthy []io.Writer
+	for _, s := range m.sinks {
+		if s.failedAt.IsZero() {
+			healthy = append(healthy, s.w)
+		}
+	}
+	return healthy
+}
+
+// each calls fn for every sink which is healthy or due a retry, and
+// tracks failure and recovery. It must be called with the lock held.
+func (m *MultiWriter) each(fn func(io.Writer) error) error {
+	now := time.Now()
+	written := 0
+	for _, s := range m.sinks {
+		if !s.failedAt.IsZero() && now.Sub(s.failedAt) < m.retry {
+			continue
+		}
+
+		err := fn(s.w)
+		switch {
+		case err != nil && s.failedAt.IsZero():
+			s.failedAt = now
+			if m.onError != nil {
+				m.onError(s.w, err)
+			}
+		case err != nil:
+			s.failedAt = now
+		case !s.failedAt.IsZero():
+			s.failedAt = time.Time{}
+			if m.onError != nil {
+				m.onError(s.w, nil)
+			}
+			written++
+		default:
+			written++
+		}
+	}
+
+	if written == 0 && len(m.sinks) > 0 {
+		return ErrAllSinksFailed
+	}
+	return nil
+}
+
+// Write implements io.Writer. An error is only returned if every sink
+// failed.
+func (m *MultiWriter) Write(data []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := m.each(func(w io.Writer) error {
+		n, err := w.Write(data)
+		if err == nil && n < len(data) {
+			err = io.ErrShortWrite
+		}
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// WriteRecord implements record.RecordWriter, passing the record to
+// sinks which accept records and the encoded line to the others.
+func (m *MultiWriter) WriteRecord(r *record.Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.each(func(w io.Writer) error {
+		if rw, ok := w.(record.RecordWriter); ok {
+			return rw.WriteRecord(r)
+		}
+		n, err := w.Write(r.Encoded)
+		if err == nil && n < len(r.Encoded) {
+			err = io.ErrShortWrite
+		}
+		return err
+	})
+}
+
+// Close closes every sink which implements io.Closer, other than the
+// standard output streams, and returns the first error.
+func (m *MultiWriter) Close() error {
+	shutdown.Untrack(m)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var firstErr error
+	for _, s := range m.sinks {
+		if s.w == os.Stdout || s.w == os.Stderr {
+			continue
+		}
+		if c, ok := s.w.(io.Closer); ok {
+			if err := c.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package logging_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/whamcloud/logging"
+	"github.com/whamcloud/logging/audit"
+	"github.com/whamcloud/logging/record"
+)
+
+type flakyWriter struct {
+	fail bool
+	buf  bytes.Buffer
+}
+
+func (w *flakyWriter) Write(data []byte) (int, error) {
+	if w.fail {
+		return 0, errors.New("disk full")
+	}
+	return w.buf.Write(data)
+}
+
+type recordSink struct {
+	records []record.Record
+}
+
+func (s *recordSink) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (s *recordSink) WriteRecord(r *record.Record) error {
+	s.records = append(s.records, *r)
+	return nil
+}
+
+func TestMultiWriterIsolation(t *testing.T) {
+	var good bytes.Buffer
+	bad := &flakyWriter{fail: true}
+
+	var reports []error
+	m := logging.NewMultiWriter(bad, &good).
+		OnError(func(w io.Writer, err error) { reports = append(reports, err) }).
+		RetryInterval(time.Hour)
+
+	for i := 0; i < 3; i++ {
+		if _, err := m.Write([]byte("line\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if good.String() != "line\nline\nline\n" {
+		t.Fatalf("healthy sink missed output: %q", good.String())
+	}
+	if len(reports) != 1 || reports[0] == nil {
+		t.Fatalf("expected a single failure report, got %v", reports)
+	}
+	if sinks := m.Sinks(); len(sinks) != 1 || sinks[0] != &good {
+		t.Fatalf("expected only the healthy sink, got %v", sinks)
+	}
+}
+
+func TestMultiWriterRecovery(t *testing.T) {
+	bad := &flakyWriter{fail: true}
+
+	var reports []error
+	m := logging.NewMultiWriter(bad).
+		OnError(func(w io.Writer, err error) { reports = append(reports, err) }).
+		RetryInterval(0)
+
+	if _, err := m.Write([]byte("lost\n")); err != logging.ErrAllSinksFailed {
+		t.Fatalf("expected ErrAllSinksFailed, got %v", err)
+	}
+	bad.fail = false
+	if _, err := m.Write([]byte("found\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if bad.buf.String() != "found\n" {
+		t.Fatalf("unexpected output after recovery: %q", bad.buf.String())
+	}
+	if len(reports) != 2 || reports[0] == nil || reports[1] != nil {
+		t.Fatalf("expected failure then recovery reports, got %v", reports)
+	}
+}
+
+func TestMultiWriterRecords(t *testing.T) {
+	var text bytes.Buffer
+	records := &recordSink{}
+
+	l := audit.NewLogger(logging.NewMultiWriter(&text, records))
+	l.LogFields("quota changed", "user", "alice")
+
+	if !strings.HasSuffix(text.String(), "quota changed user=alice\n") {
+		t.Fatalf("unexpected text output: %q", text.String())
+	}
+	if len(records.records) != 1 || records.records[0].Stream != "audit" {
+		t.Fatalf("record sink did not receive the record: %v", records.records)
+	}
+}
+
+func TestSetWriterMultiple(t *testing.T) {
+	var bufA, bufB bytes.Buffer
+	if err := logging.SetWriter(&bufA, &bufB); err != nil {
+		t.Fatal(err)
+	}
+	defer logging.SetWriter(&bytes.Buffer{})
+
+	audit.Log("to both")
+
+	if !strings.HasSuffix(bufA.String(), "to both\n") || bufA.String() != bufB.String() {
+		t.Fatalf("expected identical output, got %q and %q", bufA.String(), bufB.String())
+	}
+}
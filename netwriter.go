@@ -0,0 +1,106 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"net"
+	"os"
+	"sync"
+
+	"github.com/whamcloud/logging/shutdown"
+)
+
+type (
+	// NetWriter is an io.WriteCloser which sends each write to a
+	// network address, reconnecting if the connection is lost.
+	NetWriter struct {
+		network string
+		addr    string
+
+		mu     sync.Mutex
+		conn   net.Conn
+		closed bool
+	}
+)
+
+// NewNetWriter connects to addr over network ("tcp", "udp", "unix" or
+// "unixgram") and returns a *NetWriter.
+func NewNetWriter(network, addr string) (*NetWriter, error) {
+	w := &NetWriter{
+		network: network,
+		addr:    addr,
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// connect must be called with the lock held
+func (w *NetWriter) connect() error {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+
+	conn, err := net.Dial(w.network, w.addr)
+	if err != nil {
+		return err
+	}
+	w.conn = conn
+	return nil
+}
+
+// Write implements io.Writer. If the write fails, the connection is
+// re-established and the rest of the data, after any part which was
+// already sent, is retried once. If reconnecting fails, the original
+// write error is returned.
+func (w *NetWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+
+	var written int
+	var firstErr error
+	if w.conn != nil {
+		n, err := w.conn.Write(data)
+		if err == nil {
+			return n, nil
+		}
+		written, firstErr = n, err
+	}
+
+	if err := w.connect(); err != nil {
+		if firstErr == nil {
+			firstErr = err
+		}
+		return written, firstErr
+	}
+	n, err := w.conn.Write(data[written:])
+	return written + n, err
+}
+
+// Close closes the connection
+func (w *NetWriter) Close() error {
+	shutdown.Untrack(w)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package record
+
+import (
+	"context"
+)
+
+// Keys used for the values attached to a context
+const (
+	RequestIDKey = "request_id"
+	TraceIDKey   = "trace_id"
+	SpanIDKey    = "span_id"
+)
+
+type (
+	// contextKey is the key for the values attached to a context
+	contextKey struct{}
+
+	// contextValues is never modified once attached to a context;
+	// the With* helpers attach a modified copy instead.
+	contextValues struct {
+		requestID string
+		traceID   string
+		spanID    string
+		fields    Fields
+	}
+)
+
+func valuesFrom(ctx context.Context) contextValues {
+	if ctx == nil {
+		return contextValues{}
+	}
+	v, _ := ctx.Value(contextKey{}).(contextValues)
+	return v
+}
+
+// WithRequestID returns a context carrying the given request ID, which
+// is emitted with every record logged by the Ctx logging functions
+func WithRequestID(ctx context.Context, id string) context.Context {
+	v := valuesFrom(ctx)
+	v.requestID = id
+	return context.WithValue(ctx, contextKey{}, v)
+}
+
+// WithTrace returns a context carrying the given trace and span IDs
+func WithTrace(ctx context.Context, traceID, spanID string) context.Context {
+	v := valuesFrom(ctx)
+	v.traceID, v.spanID = traceID, spanID
+	return context.WithValue(ctx, contextKey{}, v)
+}
+
+// WithFields returns a context carrying the given alternating keys and
+// values in addition to any already attached
+func WithFields(ctx context.Context, keysAndValues ...interface{}) context.Context {
+	v := valuesFrom(ctx)
+	v.fields = v.fields.With(keysAndValues...)
+	return context.WithValue(ctx, contextKey{}, v)
+}
+
+// RequestID returns the request ID attached to the context, if any
+func RequestID(ctx context.Context) string {
+	return valuesFrom(ctx).requestID
+}
+
+// TraceID returns the trace and span IDs attached to the context, if any
+func TraceID(ctx context.Context) (string, string) {
+	v := valuesFrom(ctx)
+	return v.traceID, v.spanID
+}
+
+// ContextFields returns the values attached to the context as Fields:
+// the request ID, trace ID and span ID (if set), followed by the fields
+// attached with WithFields
+func ContextFields(ctx context.Context) Fields {
+	v := valuesFrom(ctx)
+
+	var fields Fields
+	if v.requestID != "" {
+		fields = append(fields, Field{Key: RequestIDKey, Value: v.requestID})
+	}
+	if v.traceID != "" {
+		fields = append(fields, Field{Key: TraceIDKey, Value: v.traceID})
+	}
+	if v.spanID != "" {
+		fields = append(fields, Field{Key: SpanIDKey, Value: v.spanID})
+	}
+	return append(fields, v.fields...)
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package record_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/whamcloud/logging/record"
+)
+
+func TestContextFields(t *testing.T) {
+	if fields := record.ContextFields(context.Background()); len(fields) != 0 {
+		t.Fatalf("expected no fields, got %s", fields)
+	}
+
+	ctx := record.WithFields(context.Background(), "user", "alice")
+	ctx = record.WithTrace(ctx, "t1", "s1")
+	ctx = record.WithRequestID(ctx, "r1")
+	child := record.WithFields(ctx, "op", "stat")
+
+	if id := record.RequestID(child); id != "r1" {
+		t.Fatalf("expected request ID r1, got %s", id)
+	}
+	if trace, span := record.TraceID(child); trace != "t1" || span != "s1" {
+		t.Fatalf("expected trace t1/s1, got %s/%s", trace, span)
+	}
+
+	expected := "request_id=r1 trace_id=t1 span_id=s1 user=alice op=stat"
+	if fields := record.ContextFields(child); fields.String() != expected {
+		t.Fatalf("expected %s, got %s", expected, fields)
+	}
+
+	expected = "request_id=r1 trace_id=t1 span_id=s1 user=alice"
+	if fields := record.ContextFields(ctx); fields.String() != expected {
+		t.Fatalf("parent context was modified: %s", fields)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package record
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// Encoder renders a Record as a single line of output, including
+	// the trailing newline. The text encoder follows the line with
+	// the record's stack trace, if any.
+	Encoder interface {
+		Encode(buf *bytes.Buffer, r *Record)
+	}
+
+	// CallerEncoder is implemented by Encoders which know whether they
+	// render the caller's file and line regardless of the log flags.
+	// The caller is looked up for every record written with an
+	// Encoder which doesn't implement it.
+	CallerEncoder interface {
+		EncodesCaller() bool
+	}
+
+	// TextEncoder renders records in the traditional log package
+	// format, honoring the record's prefix and log flags. Fields are
+	// appended to the message as key=value pairs.
+	TextEncoder struct{}
+
+	// JSONEncoder renders records as one JSON object per line
+	// (JSON Lines).
+	JSONEncoder struct{}
+)
+
+var (
+	// Text is the default Encoder
+	Text Encoder = TextEncoder{}
+
+	// JSON is an Encoder for JSON Lines output
+	JSON Encoder = JSONEncoder{}
+)
+
+// ParseEncoder returns the Encoder for the given name ("text" or "json")
+func ParseEncoder(name string) (Encoder, error) {
+	switch strings.ToLower(name) {
+	case "", "text":
+		return Text, nil
+	case "json", "jsonl":
+		return JSON, nil
+	default:
+		return nil, fmt.Errorf("unknown log encoder: %s", name)
+	}
+}
+
+func itoa(buf *bytes.Buffer, i int, wid int) {
+	s := strconv.Itoa(i)
+	for n := len(s); n < wid; n++ {
+		buf.WriteByte('0')
+	}
+	buf.WriteString(s)
+}
+
+// EncodesCaller implements CallerEncoder. The caller is only included
+// if the flags ask for it.
+func (TextEncoder) EncodesCaller() bool {
+	return false
+}
+
+// Encode implements Encoder
+func (TextEncoder) Encode(buf *bytes.Buffer, r *Record) {
+	if r.Flags&log.Lmsgprefix == 0 {
+		buf.WriteString(r.Prefix)
+	}
+
+	if r.Flags&(log.Ldate|log.Ltime|log.Lmicroseconds) != 0 {
+		t := r.Time
+		if r.Flags&log.LUTC != 0 {
+			t = t.UTC()
+		}
+		if r.Flags&log.Ldate != 0 {
+			year, month, day := t.Date()
+			itoa(buf, year, 4)
+			buf.WriteByte('/')
+			itoa(buf, int(month), 2)
+			buf.WriteByte('/')
+			itoa(buf, day, 2)
+			buf.WriteByte(' ')
+		}
+		if r.Flags&(log.Ltime|log.Lmicroseconds) != 0 {
+			hour, min, sec := t.Clock()
+			itoa(buf, hour, 2)
+			buf.WriteByte(':')
+			itoa(buf, min, 2)
+			buf.WriteByte(':')
+			itoa(buf, sec, 2)
+			if r.Flags&log.Lmicroseconds != 0 {
+				buf.WriteByte('.')
+				itoa(buf, t.Nanosecond()/1e3, 6)
+			}
+			buf.WriteByte(' ')
+		}
+	}
+
+	if r.Flags&(log.Lshortfile|log.Llongfile) != 0 {
+		buf.WriteString(caller(r))
+		buf.WriteString(": ")
+	}
+
+	if r.Flags&log.Lmsgprefix != 0 {
+		buf.WriteString(r.Prefix)
+	}
+
+	if r.Level != "" {
+		buf.WriteString(r.Level)
+		buf.WriteString(": ")
+	}
+	if len(r.Fields) > 0 {
+		msg := strings.TrimSuffix(r.Message, "\n")
+		if msg != "" {
+			buf.WriteString(msg)
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(r.Fields.String())
+	} else {
+		buf.WriteString(r.Message)
+	}
+
+	if buf.Len() == 0 || buf.Bytes()[buf.Len()-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+	if r.Stack != "" {
+		buf.WriteString(strings.TrimSuffix(r.Stack, "\n"))
+		buf.WriteByte('\n')
+	}
+}
+
+// jsonRecord defines the field names and order for JSONEncoder
+type jsonRecord struct {
+	Time    string `json:"time"`
+	Stream  string `json:"stream,omitempty"`
+	Level   string `json:"level,omitempty"`
+	Caller  string `json:"caller,omitempty"`
+	Message string `json:"msg"`
+	Fields  Fields `json:"fields,omitempty"`
+	Stack   string `json:"stack,omitempty"`
+}
+
+// EncodesCaller implements CallerEncoder. The caller is always included.
+func (JSONEncoder) EncodesCaller() bool {
+	return true
+}
+
+// Encode implements Encoder
+func (JSONEncoder) Encode(buf *bytes.Buffer, r *Record) {
+	t := r.Time
+	if r.Flags&log.LUTC != 0 {
+		t = t.UTC()
+	}
+
+	jr := jsonRecord{
+		Time:    t.Format(time.RFC3339Nano),
+		Stream:  r.Stream,
+		Level:   r.Level,
+		Message: strings.TrimSuffix(r.Message, "\n"),
+		Fields:  r.Fields,
+		Stack:   r.Stack,
+	}
+	if r.File != "" {
+		jr.Caller = caller(r)
+	}
+
+	data, err := json.Marshal(&jr)
+	if err != nil {
+		jr.Fields = nil
+		jr.Message = fmt.Sprintf("%s (failed to encode fields: %s)", jr.Message, err)
+		data, _ = json.Marshal(&jr)
+	}
+	buf.Write(data)
+	buf.WriteByte('\n')
+}
+
+// caller formats the record's file and line according to its flags,
+// using the short file name unless Llongfile is the only file flag.
+func caller(r *Record) string {
+	file := r.File
+	if file == "" {
+		file = "???"
+	}
+	if r.Flags&log.Lshortfile != 0 || r.Flags&log.Llongfile == 0 {
+		if i := strings.LastIndexByte(file, '/'); i >= 0 {
+			file = file[i+1:]
+		}
+	}
+	return file + ":" + strconv.Itoa(r.Line)
+}
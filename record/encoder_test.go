@@ -0,0 +1,118 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package record_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/whamcloud/logging/record"
+)
+
+var testTime = time.Date(2021, 3, 4, 5, 6, 7, 8000, time.UTC)
+
+func TestTextEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	r := &record.Record{
+		Time:    testTime,
+		Prefix:  "ALERT ",
+		Flags:   log.LstdFlags | log.Lmicroseconds | log.LUTC | log.Lshortfile,
+		File:    "/src/pkg/file.go",
+		Line:    42,
+		Message: "something happened",
+		Fields:  record.KV("user", "alice"),
+	}
+	record.Text.Encode(&buf, r)
+
+	expected := "ALERT 2021/03/04 05:06:07.000008 file.go:42: something happened user=alice\n"
+	if buf.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestTextEncoderMatchesLog(t *testing.T) {
+	var logBuf, buf bytes.Buffer
+	log.New(&logBuf, "pfx ", log.Lmsgprefix).Print("message")
+
+	record.Text.Encode(&buf, &record.Record{
+		Prefix:  "pfx ",
+		Flags:   log.Lmsgprefix,
+		Message: "message",
+	})
+
+	if buf.String() != logBuf.String() {
+		t.Fatalf("expected %q, got %q", logBuf.String(), buf.String())
+	}
+}
+
+func TestJSONEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	r := &record.Record{
+		Time:    testTime,
+		Stream:  "audit",
+		Flags:   log.LstdFlags | log.LUTC,
+		File:    "/src/pkg/file.go",
+		Line:    42,
+		Message: "quota changed\n",
+		Fields:  record.KV("user", "alice", "to", 20),
+	}
+	record.JSON.Encode(&buf, r)
+
+	if strings.Count(buf.String(), "\n") != 1 {
+		t.Fatalf("expected a single line, got %q", buf.String())
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"time":   "2021-03-04T05:06:07.000008Z",
+		"stream": "audit",
+		"caller": "file.go:42",
+		"msg":    "quota changed",
+	}
+	for key, value := range expected {
+		if decoded[key] != value {
+			t.Fatalf("%s: expected %v, got %v", key, value, decoded[key])
+		}
+	}
+	fields, ok := decoded["fields"].(map[string]interface{})
+	if !ok || fields["user"] != "alice" || fields["to"] != float64(20) {
+		t.Fatalf("unexpected fields: %v", decoded["fields"])
+	}
+}
+
+// fileEncoder renders only the record's file, like the text encoder
+// it doesn't need the caller unless the flags ask for it
+type fileEncoder struct{}
+
+func (fileEncoder) EncodesCaller() bool {
+	return false
+}
+
+func (fileEncoder) Encode(buf *bytes.Buffer, r *record.Record) {
+	buf.WriteString("file=" + r.File + "\n")
+}
+
+func TestCallerLookup(t *testing.T) {
+	var buf bytes.Buffer
+	l := record.NewLogger(&buf, "test", record.SeverityInfo, "", log.LstdFlags)
+	l.SetEncoder(fileEncoder{})
+
+	l.Output(1, record.Record{Message: "no caller"})
+	l.SetFlags(log.Lshortfile)
+	l.Output(1, record.Record{Message: "caller"})
+
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) != 3 || lines[0] != "file=" || !strings.HasSuffix(lines[1], "encoder_test.go") {
+		t.Fatalf("unexpected output: %q", lines)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package record
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type (
+	// Field is a single key/value pair attached to a log record
+	Field struct {
+		Key   string
+		Value interface{}
+	}
+
+	// Fields is an ordered list of key/value pairs. Fields are always
+	// rendered in the order in which they were added.
+	Fields []Field
+)
+
+// missingValue is used when a key is supplied without a value
+const missingValue = "(MISSING)"
+
+// KV converts alternating keys and values into Fields. Keys which are
+// not strings are converted with fmt.Sprint, and a trailing key without
+// a value is given a placeholder value.
+func KV(keysAndValues ...interface{}) Fields {
+	if len(keysAndValues) == 0 {
+		return nil
+	}
+
+	fields := make(Fields, 0, (len(keysAndValues)+1)/2)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key, ok := keysAndValues[i].(string)
+		if !ok {
+			key = fmt.Sprint(keysAndValues[i])
+		}
+		var value interface{} = missingValue
+		if i+1 < len(keysAndValues) {
+			value = keysAndValues[i+1]
+		}
+		fields = append(fields, Field{Key: key, Value: value})
+	}
+
+	return fields
+}
+
+// With returns a new Fields with the given key/value pairs appended
+func (f Fields) With(keysAndValues ...interface{}) Fields {
+	added := KV(keysAndValues...)
+	out := make(Fields, 0, len(f)+len(added))
+	out = append(out, f...)
+	return append(out, added...)
+}
+
+// String renders the fields as space-separated key=value pairs, quoting
+// keys and values which would otherwise be ambiguous.
+func (f Fields) String() string {
+	var sb strings.Builder
+	for i, field := range f {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(quote(field.Key))
+		sb.WriteByte('=')
+		sb.WriteString(quote(formatValue(field.Value)))
+	}
+	return sb.String()
+}
+
+// MarshalJSON renders the fields as a JSON object with keys in order
+func (f Fields) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range f {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(field.Key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+
+		value, err := marshalValue(field.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// formatValue uses fmt rather than calling Error() or String() directly,
+// as fmt recovers from the panic caused by a typed nil receiver
+func formatValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+func marshalValue(v interface{}) ([]byte, error) {
+	switch v.(type) {
+	case error:
+		return json.Marshal(fmt.Sprint(v))
+	case json.Marshaler:
+		return json.Marshal(v)
+	case fmt.Stringer:
+		return json.Marshal(fmt.Sprint(v))
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		// Fall back to the text representation rather than losing
+		// the value altogether.
+		return json.Marshal(fmt.Sprint(v))
+	}
+	return data, nil
+}
+
+func quote(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, r := range s {
+		if r == '=' || r == '"' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package record_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/whamcloud/logging/record"
+)
+
+func TestFieldsString(t *testing.T) {
+	fields := record.KV("user", "alice", "action", "set quota", "old", 10, "err", errors.New("oops"), "dangling")
+
+	expected := `user=alice action="set quota" old=10 err=oops dangling=(MISSING)`
+	if fields.String() != expected {
+		t.Fatalf("expected %s, got %s", expected, fields)
+	}
+}
+
+func TestFieldsJSON(t *testing.T) {
+	fields := record.KV("user", "alice", "new", 20, "ok", true)
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"user":"alice","new":20,"ok":true}`
+	if string(data) != expected {
+		t.Fatalf("expected %s, got %s", expected, data)
+	}
+}
+
+func TestFieldsWith(t *testing.T) {
+	base := record.KV("user", "alice")
+	a := base.With("action", "a")
+	b := base.With("action", "b")
+
+	if a.String() != "user=alice action=a" || b.String() != "user=alice action=b" {
+		t.Fatalf("With() modified shared fields: %s / %s", a, b)
+	}
+}
+
+type nilError struct{ msg string }
+
+func (e *nilError) Error() string {
+	return e.msg
+}
+
+func TestFieldsTypedNil(t *testing.T) {
+	var err error = (*nilError)(nil)
+	fields := record.KV("err", err)
+
+	if fields.String() != "err=<nil>" {
+		t.Fatalf("unexpected rendering of a typed nil error: %s", fields)
+	}
+	data, jerr := json.Marshal(fields)
+	if jerr != nil {
+		t.Fatal(jerr)
+	}
+	var decoded map[string]string
+	if jerr := json.Unmarshal(data, &decoded); jerr != nil || decoded["err"] != "<nil>" {
+		t.Fatalf("unexpected JSON for a typed nil error: %s", data)
+	}
+}
+
+func TestFieldsQuotedKeys(t *testing.T) {
+	fields := record.KV("user name", "alice", "a=b", 1, "", 2)
+
+	expected := `"user name"=alice "a=b"=1 ""=2`
+	if fields.String() != expected {
+		t.Fatalf("expected %s, got %s", expected, fields)
+	}
+}
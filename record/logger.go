@@ -0,0 +1,234 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package record
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"runtime"
+	"sync"
+	"time"
+)
+
+type (
+	// Logger builds Records for logging events and writes them to an
+	// io.Writer using an Encoder. It is the engine behind the alert,
+	// audit and debug loggers, and is safe for concurrent use.
+	Logger struct {
+		stream   string
+		severity Severity
+
+		mu      sync.Mutex
+		prefix  string
+		flags   int
+		out     io.Writer
+		encoder Encoder
+		sealer  Sealer
+		buf     bytes.Buffer
+	}
+
+	// Sealer is called for every record written by a Logger, while
+	// the Logger's lock is held. Prepare may add fields to the record
+	// before it is encoded, and Seal may modify the encoded line in
+	// place (without changing its length) before it is written. Seal
+	// should update the record's fields to match the sealed line.
+	Sealer interface {
+		Prepare(r *Record)
+		Seal(r *Record, line []byte)
+	}
+)
+
+// NewLogger returns a *Logger for the named stream. The prefix and
+// flags have the same meaning as for the log package, and records
+// are given the supplied severity unless the caller overrides it.
+func NewLogger(out io.Writer, stream string, severity Severity, prefix string, flags int) *Logger {
+	return &Logger{
+		stream:   stream,
+		severity: severity,
+		prefix:   prefix,
+		flags:    flags,
+		out:      out,
+		encoder:  Text,
+	}
+}
+
+// Stream returns the name of the logger's stream
+func (l *Logger) Stream() string {
+	return l.stream
+}
+
+// SetOutput sets the output destination for the logger
+func (l *Logger) SetOutput(out io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out = out
+}
+
+// Writer returns the output destination for the logger
+func (l *Logger) Writer() io.Writer {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.out
+}
+
+// SetFlags sets the output flags for the logger
+func (l *Logger) SetFlags(flags int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.flags = flags
+}
+
+// Flags returns the output flags for the logger
+func (l *Logger) Flags() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.flags
+}
+
+// SetPrefix sets the output prefix for the logger
+func (l *Logger) SetPrefix(prefix string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prefix = prefix
+}
+
+// SetEncoder sets the Encoder used to render records. A nil Encoder
+// restores the default text encoding.
+func (l *Logger) SetEncoder(e Encoder) {
+	if e == nil {
+		e = Text
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.encoder = e
+}
+
+// Encoder returns the Encoder used to render records
+func (l *Logger) Encoder() Encoder {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.encoder
+}
+
+// SetSealer sets a Sealer for every record written by the logger.
+// A nil Sealer disables sealing.
+func (l *Logger) SetSealer(s Sealer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sealer = s
+}
+
+// Output writes the output for a logging event described by r, which
+// need only contain the message and any level, severity or fields. The
+// time is stamped unless already set. As with log.Output, calldepth is
+// the number of stack frames to skip when determining the caller's file
+// and line.
+func (l *Logger) Output(calldepth int, r Record) error {
+	caller := l.needsCaller()
+	var pc uintptr
+	if caller {
+		pc = CallerPC(calldepth + 1)
+	}
+	return l.outputPC(caller, pc, r)
+}
+
+// OutputPC is like Output, but takes the caller's program counter as
+// returned by CallerPC, for callers which have already looked it up.
+func (l *Logger) OutputPC(pc uintptr, r Record) error {
+	return l.outputPC(l.needsCaller(), pc, r)
+}
+
+// outputPC writes r, setting its file and line from pc if caller is set
+func (l *Logger) outputPC(caller bool, pc uintptr, r Record) error {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	r.Stream = l.stream
+	if r.Severity == SeverityUnset {
+		r.Severity = l.severity
+	}
+	if caller {
+		if frame, ok := CallerFrame(pc); ok {
+			r.File, r.Line = frame.File, frame.Line
+		} else {
+			r.File, r.Line = "???", 0
+		}
+	}
+
+	return l.WriteRecord(&r)
+}
+
+// needsCaller indicates whether records need the caller's file and
+// line, which are relatively expensive to look up: either the flags
+// include them, the encoder always renders them, or the output is a
+// RecordWriter which may use them.
+func (l *Logger) needsCaller() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.flags&(log.Lshortfile|log.Llongfile) != 0 {
+		return true
+	}
+	if _, ok := l.out.(RecordWriter); ok {
+		return true
+	}
+	if ce, ok := l.encoder.(CallerEncoder); ok {
+		return ce.EncodesCaller()
+	}
+	return true
+}
+
+// CallerPC returns the program counter of the caller selected by
+// calldepth, which has the same meaning as for Output when CallerPC is
+// called in its place. It returns 0 if the stack is not that deep.
+func CallerPC(calldepth int) uintptr {
+	var pcs [1]uintptr
+	if runtime.Callers(calldepth+1, pcs[:]) < 1 {
+		return 0
+	}
+	return pcs[0]
+}
+
+// CallerFrame returns the file, line and function for a program
+// counter returned by CallerPC
+func CallerFrame(pc uintptr) (runtime.Frame, bool) {
+	if pc == 0 {
+		return runtime.Frame{}, false
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	return frame, frame.File != ""
+}
+
+// WriteRecord encodes the Record with the logger's prefix and flags and
+// writes it to the output. If the output is a RecordWriter, the record
+// is passed to it along with the encoded line.
+func (l *Logger) WriteRecord(r *Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r.Prefix = l.prefix
+	r.Flags = l.flags
+	if l.sealer != nil {
+		l.sealer.Prepare(r)
+	}
+
+	l.buf.Reset()
+	l.encoder.Encode(&l.buf, r)
+	if l.sealer != nil {
+		l.sealer.Seal(r, l.buf.Bytes())
+		r.Sealed = true
+	}
+
+	if rw, ok := l.out.(RecordWriter); ok {
+		r.Encoded = l.buf.Bytes()
+		err := rw.WriteRecord(r)
+		r.Encoded = nil
+		return err
+	}
+
+	_, err := l.out.Write(l.buf.Bytes())
+	return err
+}
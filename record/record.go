@@ -0,0 +1,46 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package record
+
+import (
+	"time"
+)
+
+type (
+	// Record is a single logging event, as produced by the alert,
+	// audit and debug loggers and the applog journal.
+	Record struct {
+		Time     time.Time
+		Stream   string
+		Level    string
+		Severity Severity
+		Prefix   string
+		Flags    int
+		File     string
+		Line     int
+		Message  string
+		Fields   Fields
+
+		// Stack is an optional stack trace, as returned by Stack()
+		Stack string
+
+		// Encoded is the record as rendered by the Logger's Encoder,
+		// for RecordWriters which pass it on to plain io.Writers. It
+		// is only valid for the duration of the WriteRecord call.
+		Encoded []byte
+
+		// Sealed is set when a Sealer has signed Encoded, so writers
+		// which re-render records must send Encoded unchanged for the
+		// signature to remain verifiable.
+		Sealed bool
+	}
+
+	// RecordWriter is implemented by sinks which accept Records rather
+	// than encoded lines, e.g. syslog. When a Logger's output is a
+	// RecordWriter, records are passed to it without being encoded.
+	RecordWriter interface {
+		WriteRecord(r *Record) error
+	}
+)
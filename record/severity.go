@@ -0,0 +1,67 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package record
+
+import "fmt"
+
+// Severity is the importance of a Record, for sinks such as syslog
+// which distinguish between them.
+type Severity int
+
+const (
+	// SeverityUnset means that the sink's default severity is used
+	SeverityUnset Severity = iota
+	// SeverityDebug is for debug output
+	SeverityDebug
+	// SeverityInfo is for informational messages
+	SeverityInfo
+	// SeverityNotice is for normal but significant events
+	SeverityNotice
+	// SeverityWarning is for warnings
+	SeverityWarning
+	// SeverityError is for errors
+	SeverityError
+	// SeverityCritical is for failures which stop the program
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityUnset:
+		return "unset"
+	case SeverityDebug:
+		return "debug"
+	case SeverityInfo:
+		return "info"
+	case SeverityNotice:
+		return "notice"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	case SeverityCritical:
+		return "critical"
+	default:
+		return fmt.Sprintf("Unknown severity: %d", int(s))
+	}
+}
+
+// Syslog returns the RFC 5424 numeric severity, defaulting to notice
+func (s Severity) Syslog() int {
+	switch s {
+	case SeverityDebug:
+		return 7
+	case SeverityInfo:
+		return 6
+	case SeverityWarning:
+		return 4
+	case SeverityError:
+		return 3
+	case SeverityCritical:
+		return 2
+	default:
+		return 5
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package record
+
+import (
+	"bytes"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// StackMode selects which goroutine stacks are attached to a record
+type StackMode int
+
+const (
+	// NoStack attaches no stack trace
+	NoStack StackMode = iota
+	// CurrentStack attaches the stack of the logging goroutine
+	CurrentStack
+	// AllStacks attaches the stacks of every goroutine, starting
+	// with the logging goroutine
+	AllStacks
+)
+
+// modulePath is the import path of this module, used to recognize the
+// logging frames at the top of the logging goroutine's stack
+var modulePath = strings.TrimSuffix(reflect.TypeOf(Record{}).PkgPath(), "/record")
+
+// Stack returns the current goroutine's stack (and every other
+// goroutine's, if mode is AllStacks) in a compact form, with one line
+// per frame and the frames inside this module trimmed from the top of
+// the current goroutine's stack. An empty string is returned for
+// NoStack.
+func Stack(mode StackMode) string {
+	if mode == NoStack {
+		return ""
+	}
+
+	buf := make([]byte, 8192)
+	for {
+		n := runtime.Stack(buf, mode == AllStacks)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	return compactStack(buf)
+}
+
+// compactStack rewrites runtime.Stack output so that each frame is
+// shown on one line as "function (dir/file.go:line)"
+func compactStack(trace []byte) string {
+	var out strings.Builder
+	for i, block := range bytes.Split(bytes.TrimSpace(trace), []byte("\n\n")) {
+		lines := strings.Split(string(block), "\n")
+		if i > 0 {
+			out.WriteByte('\n')
+		}
+		out.WriteString(lines[0])
+		out.WriteByte('\n')
+
+		trimming := i == 0
+		for j := 1; j+1 < len(lines); j += 2 {
+			fn := lines[j]
+			if strings.HasSuffix(fn, ")") {
+				if k := strings.LastIndexByte(fn, '('); k > 0 {
+					fn = fn[:k]
+				}
+			}
+			if trimming && isLoggingFrame(fn) {
+				continue
+			}
+			trimming = false
+
+			file := strings.TrimSpace(lines[j+1])
+			if k := strings.LastIndex(file, " +0x"); k >= 0 {
+				file = file[:k]
+			}
+			out.WriteString("    ")
+			out.WriteString(fn)
+			out.WriteString(" (")
+			out.WriteString(shortPath(file))
+			out.WriteString(")\n")
+		}
+	}
+
+	return out.String()
+}
+
+// isLoggingFrame returns true for frames inside this module (other than
+// its tests) and the runtime's stack functions
+func isLoggingFrame(fn string) bool {
+	if strings.HasPrefix(fn, "runtime.Stack") || strings.HasPrefix(fn, "runtime/debug.Stack") {
+		return true
+	}
+	if !strings.HasPrefix(fn, modulePath+"/") && !strings.HasPrefix(fn, modulePath+".") {
+		return false
+	}
+	pkg := fn[len(modulePath):]
+	if i := strings.IndexByte(pkg, '.'); i >= 0 {
+		pkg = pkg[:i]
+	}
+	return !strings.HasSuffix(pkg, "_test")
+}
+
+// shortPath keeps the last directory and file name of a path
+func shortPath(path string) string {
+	if i := strings.LastIndexByte(path, '/'); i > 0 {
+		if j := strings.LastIndexByte(path[:i], '/'); j >= 0 {
+			return path[j+1:]
+		}
+	}
+	return path
+}
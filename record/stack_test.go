@@ -0,0 +1,55 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package record_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/whamcloud/logging/record"
+)
+
+func TestStack(t *testing.T) {
+	if stack := record.Stack(record.NoStack); stack != "" {
+		t.Fatalf("expected no stack, got %s", stack)
+	}
+
+	lines := strings.Split(record.Stack(record.CurrentStack), "\n")
+	if len(lines) < 3 || !strings.HasPrefix(lines[0], "goroutine ") {
+		t.Fatalf("unexpected stack: %q", lines)
+	}
+	// The record package's own frames are trimmed
+	if !strings.HasPrefix(lines[1], "    github.com/whamcloud/logging/record_test.TestStack (record/stack_test.go:") {
+		t.Fatalf("expected the test to be the first frame: %s", lines[1])
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() { <-done }()
+	if all := record.Stack(record.AllStacks); strings.Count(all, "goroutine ") < 2 {
+		t.Fatalf("expected multiple goroutines: %s", all)
+	}
+}
+
+func TestEncodeStack(t *testing.T) {
+	r := &record.Record{
+		Time:    testTime,
+		Message: "message",
+		Stack:   "goroutine 1 [running]:\n    main.main (main/main.go:1)\n",
+	}
+
+	var buf bytes.Buffer
+	record.Text.Encode(&buf, r)
+	if buf.String() != "message\n"+r.Stack {
+		t.Fatalf("unexpected text encoding: %q", buf.String())
+	}
+
+	buf.Reset()
+	record.JSON.Encode(&buf, r)
+	if !strings.Contains(buf.String(), `"stack":"goroutine 1 [running]:\n    main.main (main/main.go:1)\n"`) {
+		t.Fatalf("unexpected JSON encoding: %s", buf.String())
+	}
+}
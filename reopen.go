@@ -0,0 +1,185 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/whamcloud/logging/shutdown"
+)
+
+type (
+	// Reopener is implemented by writers which can close and reopen
+	// their underlying file, e.g. after it has been moved by logrotate.
+	Reopener interface {
+		Reopen() error
+	}
+
+	// ReopenableFile is an io.WriteCloser for a log file which may be
+	// reopened by path without disturbing the loggers writing to it.
+	ReopenableFile struct {
+		path string
+		mode os.FileMode
+
+		mu   sync.Mutex
+		file *os.File
+	}
+)
+
+var reopeners = struct {
+	sync.Mutex
+	m map[Reopener]struct{}
+}{m: make(map[Reopener]struct{})}
+
+func registerReopener(r Reopener) {
+	reopeners.Lock()
+	defer reopeners.Unlock()
+	reopeners.m[r] = struct{}{}
+}
+
+func unregisterReopener(r Reopener) {
+	reopeners.Lock()
+	defer reopeners.Unlock()
+	delete(reopeners.m, r)
+}
+
+// OpenReopenableFile opens (or creates) the log file at path and
+// registers it to be reopened by Reopen().
+func OpenReopenableFile(path string) (*ReopenableFile, error) {
+	return OpenReopenableFileMode(path, LogFileMode)
+}
+
+// OpenReopenableFileMode is like OpenReopenableFile, but creates the
+// file with the given mode.
+func OpenReopenableFileMode(path string, mode os.FileMode) (*ReopenableFile, error) {
+	f, err := os.OpenFile(path, LogFileFlags, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	rf := &ReopenableFile{
+		path: path,
+		mode: mode,
+		file: f,
+	}
+	registerReopener(rf)
+
+	return rf, nil
+}
+
+// Name returns the path of the log file
+func (f *ReopenableFile) Name() string {
+	return f.path
+}
+
+// Write implements io.Writer
+func (f *ReopenableFile) Write(data []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	return f.file.Write(data)
+}
+
+// Reopen opens the log file by path and swaps it in for the previous
+// file, which is then closed. Writes in progress complete against the
+// previous file; writes after Reopen returns go to the new one.
+func (f *ReopenableFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+
+	newFile, err := os.OpenFile(f.path, LogFileFlags, f.mode)
+	if err != nil {
+		return err
+	}
+
+	oldFile := f.file
+	f.file = newFile
+	return oldFile.Close()
+}
+
+// Close closes the log file and stops it from being reopened
+func (f *ReopenableFile) Close() error {
+	shutdown.Untrack(f)
+	unregisterReopener(f)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// Reopen reopens every *ReopenableFile, including file paths given to
+// SetWriter or applog.JournalFile, paths opened by CreateWriter with the
+// Reopenable() option and file:// URLs, and every *RotatingWriter. The
+// files are swapped in place, so the alert, audit and applog journal
+// loggers pick up the new files without needing to be reconfigured. The first error encountered is returned after all
+// files have been attempted.
+func Reopen() error {
+	reopeners.Lock()
+	list := make([]Reopener, 0, len(reopeners.m))
+	for r := range reopeners.m {
+		list = append(list, r)
+	}
+	reopeners.Unlock()
+
+	var firstErr error
+	for _, r := range list {
+		if err := r.Reopen(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to reopen log file: %s", err)
+		}
+	}
+
+	return firstErr
+}
+
+// ReopenOnSignal calls Reopen() whenever one of the given signals is
+// received, defaulting to SIGHUP. Reopen errors are passed to the
+// optional error handler. The returned function stops signal handling.
+func ReopenOnSignal(onError func(error), sigs ...os.Signal) func() {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+
+	go func() {
+		for {
+			select {
+			case <-ch:
+				if err := Reopen(); err != nil && onError != nil {
+					onError(err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package logging_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/whamcloud/logging"
+	"github.com/whamcloud/logging/alert"
+)
+
+func TestReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtest-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	w, err := logging.CreateWriter(path, logging.Reopenable())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.(io.Closer).Close()
+	if err := logging.SetWriter(w); err != nil {
+		t.Fatal(err)
+	}
+	defer logging.SetWriter(&bytes.Buffer{})
+
+	expected := []string{"before rotation", "after rotation"}
+	alert.Warn(expected[0])
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := logging.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	alert.Warn(expected[1])
+
+	for i, name := range []string{path + ".1", path} {
+		buf, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		line := strings.TrimSuffix(string(buf), "\n")
+		if strings.Contains(line, "\n") || !strings.HasSuffix(line, expected[i]) {
+			t.Fatalf("%s: expected only %s, found %q", name, expected[i], buf)
+		}
+	}
+}
+
+func TestReopenSetWriterPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtest-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	if err := logging.SetWriter(path); err != nil {
+		t.Fatal(err)
+	}
+	defer logging.SetWriter(&bytes.Buffer{})
+
+	alert.Warn("before rotation")
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := logging.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	alert.Warn("after rotation")
+
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if os.SameFile(before, after) {
+		t.Fatal("expected a new file after Reopen")
+	}
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(buf), "\n")
+	lines = lines[:len(lines)-1] // Don't want the empty line
+	if len(lines) != 1 || !strings.HasSuffix(lines[0], "after rotation") {
+		t.Fatalf("expected only the later alert in the new file, found %q", lines)
+	}
+}
+
+func TestReopenOnSignal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtest-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	w, err := logging.CreateWriter(path, logging.Reopenable())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.(io.Closer).Close()
+
+	errors := make(chan error, 1)
+	stop := logging.ReopenOnSignal(func(err error) { errors <- err })
+	defer stop()
+
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	syscall.Kill(os.Getpid(), syscall.SIGHUP)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		select {
+		case err := <-errors:
+			t.Fatal(err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("file was not reopened after SIGHUP")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
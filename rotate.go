@@ -0,0 +1,350 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/whamcloud/logging/shutdown"
+)
+
+// backupTimeFormat is appended to the log file's name when it is
+// rotated, and sorts lexically in chronological order.
+const backupTimeFormat = "20060102T150405.000000000"
+
+type (
+	// RotatingWriter is an io.WriteCloser which writes to a log file
+	// and rotates it out of the way when it grows too large. Rotated
+	// files are optionally compressed, and pruned by count and age.
+	RotatingWriter struct {
+		path       string
+		mode       os.FileMode
+		maxSize    int64
+		maxAge     time.Duration
+		maxBackups int
+		compress   bool
+		onError    func(error)
+
+		mu     sync.Mutex
+		file   *os.File
+		size   int64
+		closed bool
+
+		// Compression and pruning of rotated files happens in the
+		// background, one rotation at a time.
+		bgMu sync.Mutex
+		bg   sync.WaitGroup
+	}
+
+	// RotateOpt sets RotatingWriter options
+	RotateOpt func(*RotatingWriter)
+)
+
+// MaxFileSize sets the size in bytes at which the log file is rotated.
+// A size of 0 disables size-based rotation.
+func MaxFileSize(size int64) RotateOpt {
+	return func(w *RotatingWriter) {
+		w.maxSize = size
+	}
+}
+
+// MaxFileAge sets the age after which rotated files are removed.
+// An age of 0 keeps rotated files regardless of age.
+func MaxFileAge(age time.Duration) RotateOpt {
+	return func(w *RotatingWriter) {
+		w.maxAge = age
+	}
+}
+
+// MaxBackups sets the number of rotated files to keep. A count of 0
+// keeps all rotated files.
+func MaxBackups(count int) RotateOpt {
+	return func(w *RotatingWriter) {
+		w.maxBackups = count
+	}
+}
+
+// CompressBackups enables gzip compression of rotated files
+func CompressBackups(compress bool) RotateOpt {
+	return func(w *RotatingWriter) {
+		w.compress = compress
+	}
+}
+
+// OnRotateError sets the handler for errors compressing rotated files,
+// which are otherwise reported on stderr. The handler is called from a
+// background goroutine, and may log through the writer.
+func OnRotateError(handler func(error)) RotateOpt {
+	return func(w *RotatingWriter) {
+		w.onError = handler
+	}
+}
+
+// RotateFileMode sets the mode used when creating log files
+func RotateFileMode(mode os.FileMode) RotateOpt {
+	return func(w *RotatingWriter) {
+		w.mode = mode
+	}
+}
+
+// NewRotatingWriter opens (or creates) the log file at path and returns
+// a *RotatingWriter configured by the supplied options. Rotated files
+// left by a previous process are pruned straight away, so that they
+// don't outlive their maximum age while waiting for a rotation.
+func NewRotatingWriter(path string, options ...RotateOpt) (*RotatingWriter, error) {
+	w := &RotatingWriter{
+		path:    path,
+		mode:    LogFileMode,
+		onError: reportRotateError,
+	}
+
+	for _, option := range options {
+		option(w)
+	}
+
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	w.prune()
+	registerReopener(w)
+
+	return w, nil
+}
+
+func reportRotateError(err error) {
+	fmt.Fprintf(os.Stderr, "logging: %s\n", err)
+}
+
+// Name returns the path of the active log file
+func (w *RotatingWriter) Name() string {
+	return w.path
+}
+
+func (w *RotatingWriter) open() error {
+	f, err := os.OpenFile(w.path, LogFileFlags, w.mode)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	w.file = f
+	w.size = info.Size()
+	return nil
+}
+
+// Write implements io.Writer. Each call is written in full to a
+// single file; rotation only happens between writes so that log
+// lines are never split across files.
+func (w *RotatingWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.ensureOpen(); err != nil {
+		return 0, err
+	}
+
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(data)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(data)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate forces rotation of the active log file
+func (w *RotatingWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.ensureOpen(); err != nil {
+		return err
+	}
+	return w.rotate()
+}
+
+// ensureOpen must be called with the lock held. If the log file could
+// not be opened after the last rotation, opening it is retried.
+func (w *RotatingWriter) ensureOpen() error {
+	if w.closed {
+		return os.ErrClosed
+	}
+	if w.file == nil {
+		return w.open()
+	}
+	return nil
+}
+
+// rotate must be called with the lock held
+func (w *RotatingWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+
+	backup := w.path + "." + time.Now().UTC().Format(backupTimeFormat)
+	if err := os.Rename(w.path, backup); err != nil && !os.IsNotExist(err) {
+		// Keep logging to the existing file rather than losing output.
+		if oerr := w.open(); oerr != nil {
+			return oerr
+		}
+		return err
+	}
+
+	// If the new file can't be opened, the next write tries again
+	// rather than the writer going silent.
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	w.bg.Add(1)
+	go func() {
+		defer w.bg.Done()
+		w.bgMu.Lock()
+		defer w.bgMu.Unlock()
+
+		if w.compress {
+			if err := compressFile(backup, w.mode); err != nil && w.onError != nil {
+				w.onError(err)
+			}
+		}
+		w.prune()
+	}()
+
+	return nil
+}
+
+// Reopen reopens the active log file by path, e.g. after it has been
+// moved by an external tool.
+func (w *RotatingWriter) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return os.ErrClosed
+	}
+
+	oldFile := w.file
+	if err := w.open(); err != nil {
+		return err
+	}
+	if oldFile == nil {
+		return nil
+	}
+	return oldFile.Close()
+}
+
+// Close closes the active log file and waits for any in-flight
+// compression of rotated files to complete.
+func (w *RotatingWriter) Close() error {
+	shutdown.Untrack(w)
+	unregisterReopener(w)
+
+	w.mu.Lock()
+	var err error
+	w.closed = true
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.mu.Unlock()
+
+	w.bg.Wait()
+	return err
+}
+
+// backups returns the rotated files for this writer, oldest first
+func (w *RotatingWriter) backups() []string {
+	// The directory is listed rather than globbed, as the path may
+	// contain glob metacharacters.
+	dir, base := filepath.Split(w.path)
+	entries, err := ioutil.ReadDir(filepath.Clean(dir + "."))
+	if err != nil {
+		return nil
+	}
+
+	var files []string
+	prefix := base + "."
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".gz")
+		if _, err := time.Parse(backupTimeFormat, stamp); err == nil {
+			files = append(files, dir+name)
+		}
+	}
+	sort.Strings(files)
+
+	return files
+}
+
+func (w *RotatingWriter) prune() {
+	files := w.backups()
+
+	if w.maxBackups > 0 && len(files) > w.maxBackups {
+		for _, f := range files[:len(files)-w.maxBackups] {
+			os.Remove(f)
+		}
+		files = files[len(files)-w.maxBackups:]
+	}
+
+	if w.maxAge > 0 {
+		cutoff := time.Now().Add(-w.maxAge)
+		for _, f := range files {
+			if info, err := os.Stat(f); err == nil && info.ModTime().Before(cutoff) {
+				os.Remove(f)
+			}
+		}
+	}
+}
+
+func compressFile(path string, mode os.FileMode) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to compress %s: %s", path, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return fmt.Errorf("failed to compress %s: %s", path, err)
+	}
+
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return fmt.Errorf("failed to compress %s: %s", path, err)
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return fmt.Errorf("failed to compress %s: %s", path, err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return fmt.Errorf("failed to compress %s: %s", path, err)
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,233 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package logging_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/whamcloud/logging"
+	"github.com/whamcloud/logging/audit"
+)
+
+func TestRotatingWriterSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtest-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	w, err := logging.NewRotatingWriter(path, logging.MaxFileSize(12), logging.MaxBackups(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{"line1\n", "line2\n", "line3\n", "line4\n", "line5\n", "line6\n", "line7\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "line7\n" {
+		t.Fatalf("expected only line7 in active file, got %q", buf)
+	}
+
+	backups, _ := filepath.Glob(path + ".*")
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups, found %q", backups)
+	}
+	buf, err = ioutil.ReadFile(backups[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "line5\nline6\n" {
+		t.Fatalf("unexpected backup contents: %q", buf)
+	}
+}
+
+func TestRotatingWriterCompress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtest-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	w, err := logging.NewRotatingWriter(path, logging.CompressBackups(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.Write([]byte("line1\n"))
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("line2\n"))
+	w.Close()
+
+	backups, _ := filepath.Glob(path + ".*")
+	if len(backups) != 1 || !strings.HasSuffix(backups[0], ".gz") {
+		t.Fatalf("expected 1 compressed backup, found %q", backups)
+	}
+
+	f, err := os.Open(backups[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "line1\n" {
+		t.Fatalf("unexpected backup contents: %q", buf)
+	}
+}
+
+func TestSetWriterRotating(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtest-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	w, err := logging.NewRotatingWriter(path, logging.MaxFileSize(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := logging.SetWriter(w); err != nil {
+		t.Fatal(err)
+	}
+	defer logging.SetWriter(&bytes.Buffer{})
+
+	for i := 0; i < 5; i++ {
+		audit.Log("rotating audit line")
+	}
+	w.Close()
+
+	backups, _ := filepath.Glob(path + ".*")
+	files := append(backups, path)
+	var lines int
+	for _, f := range files {
+		buf, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(string(buf), "\n"), "\n") {
+			if !strings.HasSuffix(line, "rotating audit line") {
+				t.Fatalf("broken line in %s: %q", f, line)
+			}
+			lines++
+		}
+	}
+	if lines != 5 {
+		t.Fatalf("expected 5 lines across %d files, found %d", len(files), lines)
+	}
+}
+
+func TestCreateWriterRotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtest-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	w, err := logging.CreateWriter(path, logging.Rotate(logging.MaxFileSize(12)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rw, ok := w.(*logging.RotatingWriter)
+	if !ok {
+		t.Fatalf("expected a *RotatingWriter, got %T", w)
+	}
+
+	for _, line := range []string{"line1\n", "line2\n", "line3\n"} {
+		rw.Write([]byte(line))
+	}
+	rw.Close()
+
+	if backups, _ := filepath.Glob(path + ".*"); len(backups) != 1 {
+		t.Fatalf("expected 1 backup, found %q", backups)
+	}
+}
+
+func TestRotatingWriterPruneOnOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtest-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	old := path + "." + time.Now().Add(-48*time.Hour).UTC().Format("20060102T150405.000000000")
+	if err := ioutil.WriteFile(old, []byte("old\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	stale := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(old, stale, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := logging.NewRotatingWriter(path, logging.MaxFileAge(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be pruned on open", old)
+	}
+}
+
+func TestRotatingWriterGlobPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtest-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Glob metacharacters in the path must not stop pruning
+	path := filepath.Join(dir, "test[1]*?.log")
+	w, err := logging.NewRotatingWriter(path, logging.MaxFileSize(12), logging.MaxBackups(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"line1\n", "line2\n", "line3\n", "line4\n", "line5\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected the active file and 1 backup, found %d files", len(entries))
+	}
+}
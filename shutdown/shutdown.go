@@ -0,0 +1,276 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package shutdown
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+type (
+	// Flusher is implemented by sinks which buffer output
+	Flusher interface {
+		Flush() error
+	}
+
+	// ExitHandler is called to terminate the process once the shutdown
+	// pipeline has run. The message is the one logged by the fatal call
+	// which caused the exit, if any.
+	ExitHandler func(code int, msg string)
+
+	// ExitPanic is the value passed to panic() by PanicOnExit
+	ExitPanic struct {
+		Code    int
+		Message string
+	}
+)
+
+// DefaultTimeout bounds how long the shutdown pipeline may take before
+// the process exits anyway
+const DefaultTimeout = 5 * time.Second
+
+var state = struct {
+	sync.Mutex
+	hooks    []func()
+	fatal    []func()
+	sinks    []io.Closer
+	timeout  time.Duration
+	code     int
+	running  bool
+	keep     bool
+	finished chan struct{}
+}{
+	timeout: DefaultTimeout,
+	code:    1,
+}
+
+var (
+	// OSExit is the default ExitHandler, which calls os.Exit()
+	OSExit ExitHandler = func(code int, _ string) {
+		os.Exit(code)
+	}
+
+	// PanicOnExit is an ExitHandler which panics with an *ExitPanic
+	// instead of exiting, so that fatal paths can be tested or
+	// recovered from. Use SetKeepSinks(true) if the program carries
+	// on logging afterwards.
+	PanicOnExit ExitHandler = func(code int, msg string) {
+		panic(&ExitPanic{Code: code, Message: msg})
+	}
+
+	exitHandler = OSExit
+)
+
+func (e *ExitPanic) Error() string {
+	return fmt.Sprintf("exit with code %d: %s", e.Code, e.Message)
+}
+
+// SetExitHandler sets the package-wide ExitHandler, which is used by
+// loggers without their own handler, and returns the previous one. A
+// nil handler restores OSExit.
+func SetExitHandler(handler ExitHandler) ExitHandler {
+	if handler == nil {
+		handler = OSExit
+	}
+	state.Lock()
+	defer state.Unlock()
+	prev := exitHandler
+	exitHandler = handler
+	return prev
+}
+
+// SetKeepSinks sets whether exiting leaves the tracked sinks open after
+// flushing them, for exit handlers which return control to the program
+// (e.g. PanicOnExit in tests), and returns the previous setting. By
+// default the sinks are closed, as the handler is expected to exit.
+func SetKeepSinks(keep bool) bool {
+	state.Lock()
+	defer state.Unlock()
+	prev := state.keep
+	state.keep = keep
+	return prev
+}
+
+// RegisterHook adds a function to be run when the process exits through
+// Exit(). Hooks run in the order in which they were registered, before
+// sinks are flushed and closed, so that anything they log is kept.
+func RegisterHook(hook func()) {
+	state.Lock()
+	defer state.Unlock()
+	state.hooks = append(state.hooks, hook)
+}
+
+// RegisterFatalHook adds a function to be run when the process exits
+// through Fatal(), before the exit hooks and the rest of the shutdown
+// pipeline, e.g. to log diagnostic state about the failure.
+func RegisterFatalHook(hook func()) {
+	state.Lock()
+	defer state.Unlock()
+	state.fatal = append(state.fatal, hook)
+}
+
+// Track adds a sink to be flushed (if it is a Flusher) and closed when
+// the process exits through Exit(). Sinks are closed in the reverse of
+// the order in which they were tracked, so wrappers are closed before
+// the sinks they wrap.
+func Track(sink io.Closer) {
+	state.Lock()
+	defer state.Unlock()
+	for _, s := range state.sinks {
+		if s == sink {
+			return
+		}
+	}
+	state.sinks = append(state.sinks, sink)
+}
+
+// Untrack removes a sink, e.g. because it has already been closed
+func Untrack(sink io.Closer) {
+	state.Lock()
+	defer state.Unlock()
+	for i, s := range state.sinks {
+		if s == sink {
+			state.sinks = append(state.sinks[:i], state.sinks[i+1:]...)
+			return
+		}
+	}
+}
+
+// SetTimeout sets the bound on how long the shutdown pipeline may take
+func SetTimeout(timeout time.Duration) {
+	state.Lock()
+	defer state.Unlock()
+	state.timeout = timeout
+}
+
+// SetFatalExitCode sets the exit code used by fatal logging calls such
+// as alert.Fatal and applog.Fail
+func SetFatalExitCode(code int) {
+	state.Lock()
+	defer state.Unlock()
+	state.code = code
+}
+
+// FatalExitCode returns the exit code used by fatal logging calls
+func FatalExitCode() int {
+	state.Lock()
+	defer state.Unlock()
+	return state.code
+}
+
+func runHook(hook func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "logging: exit hook panicked: %v\n", r)
+		}
+	}()
+	hook()
+}
+
+// Run runs the exit hooks, then flushes and closes the tracked sinks,
+// giving up after the configured timeout. Calls made while the pipeline
+// is already running wait for it to finish (or time out) instead.
+func Run() {
+	run(false, true)
+}
+
+// run runs the pipeline, starting with the fatal hooks if fatal is set,
+// and only flushing the sinks and leaving them tracked unless
+// closeSinks is set
+func run(fatal, closeSinks bool) {
+	state.Lock()
+	timeout := state.timeout
+	if state.running {
+		finished := state.finished
+		state.Unlock()
+		waitFor(finished, timeout)
+		return
+	}
+	state.running = true
+	state.finished = make(chan struct{})
+	finished := state.finished
+	var hooks []func()
+	if fatal {
+		hooks = append(hooks, state.fatal...)
+	}
+	hooks = append(hooks, state.hooks...)
+	sinks := append([]io.Closer{}, state.sinks...)
+	if closeSinks {
+		state.sinks = nil
+	}
+	state.Unlock()
+
+	go func() {
+		defer func() {
+			state.Lock()
+			state.running = false
+			state.Unlock()
+			close(finished)
+		}()
+
+		for _, hook := range hooks {
+			runHook(hook)
+		}
+		for i := len(sinks) - 1; i >= 0; i-- {
+			if f, ok := sinks[i].(Flusher); ok {
+				f.Flush()
+			}
+			if closeSinks {
+				sinks[i].Close()
+			}
+		}
+	}()
+
+	if !waitFor(finished, timeout) {
+		fmt.Fprintf(os.Stderr, "logging: shutdown did not complete within %s\n", timeout)
+	}
+}
+
+func waitFor(finished chan struct{}, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-finished:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Exit runs the shutdown pipeline and then exits with the given code
+func Exit(code int) {
+	ExitWith(nil, code, "")
+}
+
+// ExitWith runs the shutdown pipeline and then calls handler, or the
+// package-wide ExitHandler if handler is nil. The tracked sinks are
+// closed unless SetKeepSinks(true) has been called.
+func ExitWith(handler ExitHandler, code int, msg string) {
+	exitWith(handler, code, msg, false)
+}
+
+func exitWith(handler ExitHandler, code int, msg string, fatal bool) {
+	state.Lock()
+	if handler == nil {
+		handler = exitHandler
+	}
+	keep := state.keep
+	state.Unlock()
+
+	run(fatal, !keep)
+	handler(code, msg)
+}
+
+// Fatal runs the shutdown pipeline, starting with the fatal hooks, and
+// then exits with the fatal exit code through handler (or the
+// package-wide ExitHandler if nil). The fatal hooks are bounded by the
+// same timeout as the rest of the pipeline.
+func Fatal(handler ExitHandler, msg string) {
+	exitWith(handler, FatalExitCode(), msg, true)
+}
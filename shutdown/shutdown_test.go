@@ -0,0 +1,150 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package shutdown_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/whamcloud/logging/shutdown"
+)
+
+type testSink struct {
+	name   string
+	events *[]string
+	block  chan struct{}
+}
+
+func (s *testSink) Flush() error {
+	*s.events = append(*s.events, "flush "+s.name)
+	return nil
+}
+
+func (s *testSink) Close() error {
+	if s.block != nil {
+		<-s.block
+	}
+	*s.events = append(*s.events, "close "+s.name)
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	var events []string
+	shutdown.RegisterHook(func() { events = append(events, "hook") })
+	shutdown.Track(&testSink{name: "inner", events: &events})
+	shutdown.Track(&testSink{name: "outer", events: &events})
+	untracked := &testSink{name: "untracked", events: &events}
+	shutdown.Track(untracked)
+	shutdown.Untrack(untracked)
+
+	shutdown.Run()
+
+	expected := []string{"hook", "flush outer", "close outer", "flush inner", "close inner"}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %q, got %q", expected, events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Fatalf("expected %q, got %q", expected, events)
+		}
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	var events []string
+	block := make(chan struct{})
+	defer func() {
+		// Let the timed out pipeline finish before other tests run
+		close(block)
+		shutdown.Run()
+	}()
+
+	shutdown.Track(&testSink{name: "hung", events: &events, block: block})
+	shutdown.SetTimeout(50 * time.Millisecond)
+	defer shutdown.SetTimeout(shutdown.DefaultTimeout)
+
+	start := time.Now()
+	shutdown.Run()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("shutdown took %s despite timeout", elapsed)
+	}
+}
+
+func TestFatalHook(t *testing.T) {
+	var events []string
+	shutdown.RegisterFatalHook(func() { events = append(events, "fatal hook") })
+	shutdown.RegisterHook(func() { events = append(events, "hook") })
+
+	shutdown.Fatal(func(code int, msg string) {
+		events = append(events, "exit "+msg)
+	}, "boom")
+
+	expected := []string{"fatal hook", "hook", "exit boom"}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %q, got %q", expected, events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Fatalf("expected %q, got %q", expected, events)
+		}
+	}
+
+	// Plain exits don't run fatal hooks
+	events = nil
+	shutdown.ExitWith(func(int, string) {}, 0, "")
+	if len(events) != 1 || events[0] != "hook" {
+		t.Fatalf("expected only the exit hook, got %q", events)
+	}
+}
+
+func TestKeepSinks(t *testing.T) {
+	var events []string
+	shutdown.Track(&testSink{name: "sink", events: &events})
+
+	// Handlers are expected to exit, even when wrapping os.Exit
+	shutdown.ExitWith(func(int, string) {}, 0, "")
+	if len(events) != 2 || events[1] != "close sink" {
+		t.Fatalf("expected the sink to be closed, got %q", events)
+	}
+
+	// Unless the program is known to carry on, in which case sinks are
+	// flushed but stay open and tracked
+	events = nil
+	shutdown.Track(&testSink{name: "sink", events: &events})
+	defer shutdown.SetKeepSinks(shutdown.SetKeepSinks(true))
+	shutdown.ExitWith(func(int, string) {}, 0, "")
+	if len(events) != 1 || events[0] != "flush sink" {
+		t.Fatalf("expected only a flush, got %q", events)
+	}
+
+	events = nil
+	shutdown.Run()
+	if len(events) != 2 || events[1] != "close sink" {
+		t.Fatalf("expected the sink to be closed by Run, got %q", events)
+	}
+}
+
+func TestFatalHookTimeout(t *testing.T) {
+	block := make(chan struct{})
+	defer func() {
+		// Let the timed out pipeline finish before other tests run
+		close(block)
+		shutdown.Run()
+	}()
+
+	// e.g. a hook logging to a dead syslog socket
+	shutdown.RegisterFatalHook(func() { <-block })
+	shutdown.SetTimeout(50 * time.Millisecond)
+	defer shutdown.SetTimeout(shutdown.DefaultTimeout)
+
+	exited := make(chan struct{})
+	go shutdown.Fatal(func(int, string) { close(exited) }, "boom")
+
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a blocked fatal hook prevented exit")
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package sloghandler provides a slog.Handler which routes records to
+// this library's loggers, so that code using log/slog shares their
+// sinks and formatting.
+package sloghandler
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/whamcloud/logging/alert"
+	"github.com/whamcloud/logging/applog"
+	"github.com/whamcloud/logging/audit"
+	"github.com/whamcloud/logging/debug"
+	"github.com/whamcloud/logging/internal/loggers"
+	"github.com/whamcloud/logging/record"
+)
+
+type (
+	// Handler implements slog.Handler. Records below slog.LevelInfo
+	// go to a *debug.Debugger, records below slog.LevelWarn go to an
+	// *applog.AppLogger (or an *audit.Logger if one is set), and the
+	// rest go to an *alert.Logger.
+	Handler struct {
+		loggers loggers.Set
+		audit   *audit.Logger
+
+		attrs record.Fields
+		group string
+	}
+
+	// OptSetter sets handler options
+	OptSetter func(*Handler)
+)
+
+// Debugger sets the debugger which receives records below slog.LevelInfo.
+// By default, this is the package-level debugger.
+func Debugger(d *debug.Debugger) OptSetter {
+	return func(h *Handler) {
+		h.loggers.Debug = d
+	}
+}
+
+// AppLogger sets the logger which receives informational records, which
+// are displayed at the USER level. By default, this is the standard
+// applog logger.
+func AppLogger(l *applog.AppLogger) OptSetter {
+	return func(h *Handler) {
+		h.loggers.App = l
+	}
+}
+
+// Audit sends informational records to an audit logger instead of applog
+func Audit(l *audit.Logger) OptSetter {
+	return func(h *Handler) {
+		h.audit = l
+	}
+}
+
+// Alert sets the logger which receives records at slog.LevelWarn and
+// above. By default, this is the package-level alert logger.
+func Alert(l *alert.Logger) OptSetter {
+	return func(h *Handler) {
+		h.loggers.Alert = l
+	}
+}
+
+// New returns a new *Handler
+func New(options ...OptSetter) *Handler {
+	h := &Handler{}
+	for _, option := range options {
+		option(h)
+	}
+	return h
+}
+
+// NewLogger returns a *slog.Logger which uses a new *Handler
+func NewLogger(options ...OptSetter) *slog.Logger {
+	return slog.New(New(options...))
+}
+
+// verbosity maps a slog level below slog.LevelInfo onto a debug
+// verbosity level: slog.LevelDebug is 1, slog.LevelDebug-4 is 2, etc.
+func verbosity(level slog.Level) int {
+	if level >= slog.LevelDebug {
+		return debug.DefaultVerbosity
+	}
+	return debug.DefaultVerbosity + int(slog.LevelDebug-level+3)/4
+}
+
+// severity maps a slog level at or above slog.LevelWarn onto a record
+// severity for the alert logger
+func severity(level slog.Level) record.Severity {
+	if level >= slog.LevelError {
+		return record.SeverityError
+	}
+	return record.SeverityWarning
+}
+
+// Enabled reports whether the handler would log a record at the level.
+// Debug records are only enabled if the debugger could log them.
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	if level < slog.LevelInfo {
+		return h.loggers.Debugger().Active(verbosity(level))
+	}
+	return true
+}
+
+// Handle routes the record to the logger for its level. The values
+// attached to the context with record.WithRequestID and friends are
+// emitted first, followed by the handler's attributes and then the
+// record's, with group names joined to keys by dots.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	fields := append(record.ContextFields(ctx), h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendAttr(fields, h.group, a)
+		return true
+	})
+	rec := record.Record{Time: r.Time, Message: r.Message, Fields: fields}
+
+	switch {
+	case r.Level < slog.LevelInfo:
+		h.loggers.Debugger().OutputPC(r.PC, verbosity(r.Level), rec)
+	case r.Level < slog.LevelWarn:
+		if h.audit != nil {
+			return h.audit.OutputPC(r.PC, rec)
+		}
+		h.loggers.AppLogger().OutputPC(r.PC, applog.USER, rec)
+	default:
+		rec.Severity = severity(r.Level)
+		return h.loggers.AlertLogger().OutputPC(r.PC, rec)
+	}
+	return nil
+}
+
+// WithAttrs returns a handler which emits the attributes with every
+// record
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	h2 := *h
+	h2.attrs = h.attrs[:len(h.attrs):len(h.attrs)]
+	for _, a := range attrs {
+		h2.attrs = appendAttr(h2.attrs, h.group, a)
+	}
+	return &h2
+}
+
+// WithGroup returns a handler which qualifies the keys of later
+// attributes with the group name
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	h2 := *h
+	h2.group = h.group + name + "."
+	return &h2
+}
+
+// appendAttr flattens the attribute onto fields, resolving its value and
+// prefixing the keys of any group members
+func appendAttr(fields record.Fields, prefix string, a slog.Attr) record.Fields {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, member := range a.Value.Group() {
+			fields = appendAttr(fields, prefix, member)
+		}
+		return fields
+	}
+
+	return append(fields, record.Field{Key: prefix + a.Key, Value: a.Value.Any()})
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package sloghandler_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/whamcloud/logging/alert"
+	"github.com/whamcloud/logging/applog"
+	"github.com/whamcloud/logging/audit"
+	"github.com/whamcloud/logging/debug"
+	"github.com/whamcloud/logging/record"
+	"github.com/whamcloud/logging/sloghandler"
+)
+
+func TestRouting(t *testing.T) {
+	var debugBuf, journalBuf, alertBuf bytes.Buffer
+	d := debug.NewDebugger(&debugBuf)
+	d.Enable()
+	app := applog.New(applog.JournalFile(&journalBuf), applog.DisplayLevel(applog.SILENT))
+
+	logger := sloghandler.NewLogger(
+		sloghandler.Debugger(d),
+		sloghandler.AppLogger(app),
+		sloghandler.Alert(alert.NewLogger(&alertBuf)),
+	)
+
+	logger.Debug("debug message", "n", 1)
+	logger.Log(context.Background(), slog.LevelDebug-4, "verbose message")
+	logger.Info("info message", "user", "alice")
+	logger.Warn("warn message")
+	logger.Error("error message", "err", "oops")
+
+	lines := strings.Split(debugBuf.String(), "\n")
+	lines = lines[:len(lines)-1] // Don't want the empty line
+	if len(lines) != 1 || !strings.HasSuffix(lines[0], "debug message n=1") {
+		t.Fatalf("unexpected debug output: %q", lines)
+	}
+	if !strings.Contains(lines[0], "sloghandler_test.go:") {
+		t.Fatalf("expected the caller's location, got %q", lines[0])
+	}
+
+	if !strings.HasSuffix(journalBuf.String(), "info message user=alice\n") {
+		t.Fatalf("unexpected journal output: %q", journalBuf.String())
+	}
+
+	lines = strings.Split(alertBuf.String(), "\n")
+	lines = lines[:len(lines)-1] // Don't want the empty line
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "warn message") || !strings.HasSuffix(lines[1], "error message err=oops") {
+		t.Fatalf("unexpected alert output: %q", lines)
+	}
+	if !strings.Contains(lines[1], "sloghandler/sloghandler_test.go:") {
+		t.Fatalf("expected the caller's location, got %q", lines[1])
+	}
+
+	d.SetVerbosity(2)
+	logger.Log(context.Background(), slog.LevelDebug-4, "verbose message")
+	if !strings.HasSuffix(debugBuf.String(), "verbose message\n") {
+		t.Fatalf("verbose message was not logged: %q", debugBuf.String())
+	}
+}
+
+func TestAttrsAndGroups(t *testing.T) {
+	var buf bytes.Buffer
+	logger := sloghandler.NewLogger(sloghandler.Audit(audit.NewLogger(&buf)))
+
+	ctx := record.WithRequestID(context.Background(), "r1")
+	logger.With("a", 1).WithGroup("g").With("b", 2).InfoContext(ctx, "msg",
+		"c", 3, slog.Group("h", "d", 4), slog.Group("", "e", 5), slog.Group("empty"))
+
+	expected := "msg request_id=r1 a=1 g.b=2 g.c=3 g.h.d=4 g.e=5\n"
+	if !strings.HasSuffix(buf.String(), expected) {
+		t.Fatalf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	d := debug.NewDebugger(nil)
+	h := sloghandler.New(sloghandler.Debugger(d))
+
+	if h.Enabled(context.Background(), slog.LevelDebug) {
+		t.Fatal("debug level enabled with debugging disabled")
+	}
+	d.Enable()
+	if !h.Enabled(context.Background(), slog.LevelDebug) || h.Enabled(context.Background(), slog.LevelDebug-4) {
+		t.Fatal("expected only verbosity 1 to be enabled")
+	}
+	if !h.Enabled(context.Background(), slog.LevelInfo) {
+		t.Fatal("info level should always be enabled")
+	}
+}
+
+func TestRecordTime(t *testing.T) {
+	var alertBuf bytes.Buffer
+	h := sloghandler.New(sloghandler.Alert(alert.NewLogger(&alertBuf)))
+
+	when := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	if err := h.Handle(context.Background(), slog.NewRecord(when, slog.LevelWarn, "replayed", 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(alertBuf.String(), "ALERT 2001/02/03 04:05:06 ") {
+		t.Fatalf("expected the record's time, got %q", alertBuf.String())
+	}
+}
@@ -0,0 +1,356 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"log/syslog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/whamcloud/logging/record"
+	"github.com/whamcloud/logging/shutdown"
+)
+
+// SyslogFormat selects the syslog message format
+type SyslogFormat int
+
+const (
+	// RFC5424 is the structured syslog protocol format
+	RFC5424 SyslogFormat = iota
+	// RFC3164 is the traditional BSD syslog format
+	RFC3164
+)
+
+const rfc5424Time = "2006-01-02T15:04:05.000000Z07:00"
+
+// localSyslogPaths are tried in order when no address is given
+var localSyslogPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+var syslogFacilities = map[string]syslog.Priority{
+	"kern":     syslog.LOG_KERN,
+	"user":     syslog.LOG_USER,
+	"mail":     syslog.LOG_MAIL,
+	"daemon":   syslog.LOG_DAEMON,
+	"auth":     syslog.LOG_AUTH,
+	"syslog":   syslog.LOG_SYSLOG,
+	"authpriv": syslog.LOG_AUTHPRIV,
+	"local0":   syslog.LOG_LOCAL0,
+	"local1":   syslog.LOG_LOCAL1,
+	"local2":   syslog.LOG_LOCAL2,
+	"local3":   syslog.LOG_LOCAL3,
+	"local4":   syslog.LOG_LOCAL4,
+	"local5":   syslog.LOG_LOCAL5,
+	"local6":   syslog.LOG_LOCAL6,
+	"local7":   syslog.LOG_LOCAL7,
+}
+
+type (
+	// SyslogWriter sends log records to a syslog daemon over a unix
+	// socket or a UDP/TCP connection. Record severities are mapped
+	// onto syslog severities, and the facility is chosen by the
+	// record's stream, with audit records going to authpriv.
+	SyslogWriter struct {
+		network  string
+		addr     string
+		format   SyslogFormat
+		tag      string
+		hostname string
+		facility syslog.Priority
+		streams  map[string]syslog.Priority
+
+		mu       sync.Mutex
+		conn     net.Conn
+		connType string
+	}
+
+	// SyslogOpt sets SyslogWriter options
+	SyslogOpt func(*SyslogWriter)
+)
+
+// SyslogTag sets the APP-NAME (or TAG) for messages, which defaults
+// to the program name
+func SyslogTag(tag string) SyslogOpt {
+	return func(w *SyslogWriter) {
+		w.tag = tag
+	}
+}
+
+// SyslogFacility sets the default facility for messages
+func SyslogFacility(facility syslog.Priority) SyslogOpt {
+	return func(w *SyslogWriter) {
+		w.facility = facility
+	}
+}
+
+// SyslogStreamFacility sets the facility for messages from the named
+// stream (e.g. "audit")
+func SyslogStreamFacility(stream string, facility syslog.Priority) SyslogOpt {
+	return func(w *SyslogWriter) {
+		w.streams[stream] = facility
+	}
+}
+
+// SyslogMessageFormat sets the message format, which defaults to RFC5424
+func SyslogMessageFormat(format SyslogFormat) SyslogOpt {
+	return func(w *SyslogWriter) {
+		w.format = format
+	}
+}
+
+// NewSyslogWriter returns a *SyslogWriter which sends messages to addr
+// over the given network ("udp", "tcp", "unix" or "unixgram"). If both
+// are empty, the local syslog socket is used.
+func NewSyslogWriter(network, addr string, options ...SyslogOpt) (*SyslogWriter, error) {
+	hostname, _ := os.Hostname()
+	w := &SyslogWriter{
+		network:  network,
+		addr:     addr,
+		tag:      progName(),
+		hostname: hostname,
+		facility: syslog.LOG_USER,
+		streams: map[string]syslog.Priority{
+			"audit": syslog.LOG_AUTHPRIV,
+		},
+	}
+
+	for _, option := range options {
+		option(w)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// NewSyslogWriterFromURL returns a *SyslogWriter described by a URL
+// such as "syslog://" (local socket), "syslog:///dev/log",
+// "syslog://host:514" (UDP), "syslog+tcp://host:601" or
+// "syslog+unix:///path". The query may set "tag", "facility" and
+// "format" ("rfc5424" or "rfc3164").
+func NewSyslogWriterFromURL(spec string) (*SyslogWriter, error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	var network, addr string
+	switch strings.ToLower(u.Scheme) {
+	case "syslog":
+		if u.Host != "" {
+			network, addr = "udp", u.Host
+		} else {
+			addr = u.Path
+		}
+	case "syslog+udp":
+		network, addr = "udp", u.Host
+	case "syslog+tcp":
+		network, addr = "tcp", u.Host
+	case "syslog+unix":
+		network, addr = "unix", u.Path
+	case "syslog+unixgram":
+		network, addr = "unixgram", u.Path
+	default:
+		return nil, fmt.Errorf("unsupported syslog URL scheme: %s", u.Scheme)
+	}
+	if (network == "udp" || network == "tcp") && u.Port() == "" {
+		addr = net.JoinHostPort(addr, "514")
+	}
+
+	var options []SyslogOpt
+	query := u.Query()
+	if tag := query.Get("tag"); tag != "" {
+		options = append(options, SyslogTag(tag))
+	}
+	if name := query.Get("facility"); name != "" {
+		facility, ok := syslogFacilities[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown syslog facility: %s", name)
+		}
+		options = append(options, SyslogFacility(facility))
+	}
+	switch strings.ToLower(query.Get("format")) {
+	case "", "rfc5424", "5424":
+	case "rfc3164", "3164":
+		options = append(options, SyslogMessageFormat(RFC3164))
+	default:
+		return nil, fmt.Errorf("unknown syslog format: %s", query.Get("format"))
+	}
+
+	return NewSyslogWriter(network, addr, options...)
+}
+
+func progName() string {
+	if len(os.Args) == 0 {
+		return "-"
+	}
+	name := os.Args[0]
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// connect must be called with the lock held
+func (w *SyslogWriter) connect() error {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+
+	if w.network != "" {
+		conn, err := net.Dial(w.network, w.addr)
+		if err != nil {
+			return err
+		}
+		w.conn, w.connType = conn, w.network
+		return nil
+	}
+
+	paths := localSyslogPaths
+	if w.addr != "" {
+		paths = []string{w.addr}
+	}
+	for _, path := range paths {
+		for _, network := range []string{"unixgram", "unix"} {
+			conn, err := net.Dial(network, path)
+			if err == nil {
+				w.conn, w.connType = conn, network
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("unable to connect to local syslog at %s", strings.Join(paths, ", "))
+}
+
+// frame formats a single syslog message
+func (w *SyslogWriter) frame(r *record.Record) []byte {
+	facility, ok := w.streams[r.Stream]
+	if !ok {
+		facility = w.facility
+	}
+	pri := int(facility) | r.Severity.Syslog()
+
+	// Syslog has its own timestamp, and identifies the stream in the
+	// header, so only the caller and message are rendered in the body.
+	// Sealed records are sent as signed, so they can be verified.
+	var body bytes.Buffer
+	if r.Sealed && len(r.Encoded) > 0 {
+		body.Write(r.Encoded)
+	} else {
+		record.Text.Encode(&body, &record.Record{
+			Flags:   r.Flags & (log.Lshortfile | log.Llongfile),
+			File:    r.File,
+			Line:    r.Line,
+			Level:   r.Level,
+			Message: r.Message,
+			Fields:  r.Fields,
+		})
+	}
+	msg := bytes.TrimSuffix(body.Bytes(), []byte("\n"))
+
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	var buf bytes.Buffer
+	switch w.format {
+	case RFC3164:
+		fmt.Fprintf(&buf, "<%d>%s ", pri, t.Format(time.Stamp))
+		if w.network == "udp" || w.network == "tcp" {
+			fmt.Fprintf(&buf, "%s ", nilValue(w.hostname))
+		}
+		fmt.Fprintf(&buf, "%s[%d]: %s", w.tag, os.Getpid(), msg)
+	default:
+		fmt.Fprintf(&buf, "<%d>1 %s %s %s %d %s - %s",
+			pri, t.Format(rfc5424Time), nilValue(w.hostname), nilValue(w.tag),
+			os.Getpid(), nilValue(r.Stream), msg)
+	}
+
+	if w.connType == "tcp" || w.connType == "unix" {
+		if w.format == RFC3164 {
+			buf.WriteByte('\n')
+			return buf.Bytes()
+		}
+		// RFC 6587 octet counting for stream transports
+		return append([]byte(strconv.Itoa(buf.Len())+" "), buf.Bytes()...)
+	}
+	return buf.Bytes()
+}
+
+func nilValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return strings.ReplaceAll(s, " ", "_")
+}
+
+func (w *SyslogWriter) send(r *record.Record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		if err := w.connect(); err != nil {
+			return err
+		}
+	}
+	if _, err := w.conn.Write(w.frame(r)); err == nil {
+		return nil
+	}
+
+	// The daemon may have restarted; reconnect and try once more.
+	if err := w.connect(); err != nil {
+		return err
+	}
+	_, err := w.conn.Write(w.frame(r))
+	return err
+}
+
+// WriteRecord implements record.RecordWriter
+func (w *SyslogWriter) WriteRecord(r *record.Record) error {
+	return w.send(r)
+}
+
+// Write implements io.Writer, sending data as a single message at
+// notice severity.
+func (w *SyslogWriter) Write(data []byte) (int, error) {
+	err := w.send(&record.Record{
+		Time:     time.Now(),
+		Severity: record.SeverityNotice,
+		Message:  string(bytes.TrimSuffix(data, []byte("\n"))),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Close closes the connection to the syslog daemon
+func (w *SyslogWriter) Close() error {
+	shutdown.Untrack(w)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
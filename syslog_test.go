@@ -0,0 +1,136 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package logging_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/whamcloud/logging"
+	"github.com/whamcloud/logging/alert"
+	"github.com/whamcloud/logging/audit"
+)
+
+func TestSyslogUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	w, err := logging.CreateWriter("syslog://" + conn.LocalAddr().String() + "?tag=logtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.(io.Closer).Close()
+
+	a := alert.NewLogger(w)
+	a.Warn("disk is getting full")
+	l := audit.NewLogger(w)
+	l.LogFields("quota changed", "user", "alice")
+
+	expected := []*regexp.Regexp{
+		// user.warning
+		regexp.MustCompile(`^<12>1 \S+ \S+ logtest \d+ alert - \S+syslog_test.go:\d+: disk is getting full$`),
+		// authpriv.notice
+		regexp.MustCompile(`^<85>1 \S+ \S+ logtest \d+ audit - quota changed user=alice$`),
+	}
+
+	buf := make([]byte, 1024)
+	for _, re := range expected {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !re.Match(buf[:n]) {
+			t.Fatalf("message %q does not match %s", buf[:n], re)
+		}
+	}
+}
+
+func TestSyslogHashChain(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	w, err := logging.CreateWriter("syslog://" + conn.LocalAddr().String() + "?tag=logtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.(io.Closer).Close()
+
+	key := []byte("secret")
+	l := audit.NewLogger(w)
+	l.EnableHashChain(key)
+	l.LogFields("quota changed", "user", "alice")
+	l.LogFields("quota changed", "user", "bob")
+
+	header := regexp.MustCompile(`^<85>1 \S+ \S+ logtest \d+ audit - `)
+	var received strings.Builder
+	buf := make([]byte, 1024)
+	for i := 0; i < 2; i++ {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		loc := header.FindIndex(buf[:n])
+		if loc == nil {
+			t.Fatalf("message %q has no syslog header", buf[:n])
+		}
+		received.Write(buf[loc[1]:n])
+		received.WriteByte('\n')
+	}
+
+	state, err := audit.VerifyChain(strings.NewReader(received.String()), key)
+	if err != nil {
+		t.Fatalf("chain received over syslog does not verify: %s\n%s", err, received.String())
+	}
+	if state.Seq != 2 {
+		t.Fatalf("expected seq 2, got %d", state.Seq)
+	}
+}
+
+func TestSyslogTCP3164(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	w, err := logging.NewSyslogWriter("tcp", ln.Addr().String(),
+		logging.SyslogTag("logtest"), logging.SyslogMessageFormat(logging.RFC3164))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	fmt.Fprint(w, "plain write\n")
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(line, "<13>") || !strings.HasSuffix(line, " logtest["+fmt.Sprint(os.Getpid())+"]: plain write\n") {
+		t.Fatalf("unexpected message: %q", line)
+	}
+}
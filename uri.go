@@ -0,0 +1,241 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WriterFactory creates an io.Writer from a sink URL
+type WriterFactory func(u *url.URL) (io.Writer, error)
+
+var schemes = struct {
+	sync.RWMutex
+	m map[string]WriterFactory
+}{m: make(map[string]WriterFactory)}
+
+func init() {
+	RegisterScheme("file", fileWriter)
+	for _, scheme := range []string{"tcp", "udp", "unix", "unixgram"} {
+		RegisterScheme(scheme, netWriter)
+	}
+	for _, scheme := range []string{"syslog", "syslog+udp", "syslog+tcp", "syslog+unix", "syslog+unixgram"} {
+		RegisterScheme(scheme, func(u *url.URL) (io.Writer, error) {
+			return NewSyslogWriterFromURL(u.String())
+		})
+	}
+	RegisterScheme("journald", func(u *url.URL) (io.Writer, error) {
+		return NewJournaldWriter(u.Path)
+	})
+}
+
+// RegisterScheme registers a WriterFactory for URLs with the given
+// scheme, replacing any existing factory for that scheme.
+func RegisterScheme(scheme string, factory WriterFactory) {
+	schemes.Lock()
+	defer schemes.Unlock()
+	schemes.m[strings.ToLower(scheme)] = factory
+}
+
+// isURL returns true if the string should be treated as a sink URL
+// rather than a file path
+func isURL(spec string) bool {
+	if strings.HasPrefix(strings.ToLower(spec), "multi:") {
+		return true
+	}
+	i := strings.Index(spec, "://")
+	if i <= 0 {
+		return false
+	}
+	for _, r := range spec[:i] {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '+' || r == '-' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// createURLWriter creates an io.Writer for the given sink URL
+func createURLWriter(spec string, options []WriterOpt) (io.Writer, error) {
+	if strings.HasPrefix(strings.ToLower(spec), "multi:") {
+		return multiWriter(spec[len("multi:"):], options)
+	}
+
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	schemes.RLock()
+	factory, ok := schemes.m[strings.ToLower(u.Scheme)]
+	schemes.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no writer registered for scheme %q", u.Scheme)
+	}
+
+	query := u.Query()
+	size, policy := query.Get("async"), query.Get("overflow")
+	if size == "" && policy == "" {
+		return factory(u)
+	}
+
+	// Any sink may be made asynchronous with e.g. ?async=1024&overflow=drop-oldest
+	queueSize := DefaultAsyncQueueSize
+	if size != "" {
+		if queueSize, err = strconv.Atoi(size); err != nil {
+			return nil, fmt.Errorf("invalid async queue size %q: %s", size, err)
+		}
+	}
+	overflow := Block
+	if policy != "" {
+		if overflow, err = ParseOverflowPolicy(policy); err != nil {
+			return nil, err
+		}
+	}
+
+	w, err := factory(u)
+	if err != nil {
+		return nil, err
+	}
+	return NewAsyncWriter(w, queueSize, overflow), nil
+}
+
+// multiWriter creates a *MultiWriter for a comma-separated list of sinks
+func multiWriter(specs string, options []WriterOpt) (io.Writer, error) {
+	m := NewMultiWriter()
+	for _, spec := range strings.Split(specs, ",") {
+		w, err := CreateWriter(strings.TrimSpace(spec), options...)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		m.Add(w)
+	}
+
+	return m, nil
+}
+
+func netWriter(u *url.URL) (io.Writer, error) {
+	addr := u.Host
+	if u.Scheme == "unix" || u.Scheme == "unixgram" {
+		addr = u.Path
+	}
+	return NewNetWriter(strings.ToLower(u.Scheme), addr)
+}
+
+// fileWriter handles file:///path URLs. The query may set "mode"
+// (octal), "rotate" (maximum size, e.g. 100MB), "maxage" (e.g. 7d),
+// "backups" and "compress".
+func fileWriter(u *url.URL) (io.Writer, error) {
+	path := u.Path
+	if u.Opaque != "" {
+		path = u.Opaque
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, fmt.Errorf("file URL must not have a remote host: %s", u)
+	}
+	if path == "" {
+		return nil, fmt.Errorf("file URL has no path: %s", u)
+	}
+
+	query := u.Query()
+	mode := os.FileMode(LogFileMode)
+	if value := query.Get("mode"); value != "" {
+		m, err := strconv.ParseUint(value, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file mode %q: %s", value, err)
+		}
+		mode = os.FileMode(m)
+	}
+
+	var options []RotateOpt
+	if value := query.Get("rotate"); value != "" {
+		size, err := ParseSize(value)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, MaxFileSize(size))
+	}
+	if value := query.Get("maxage"); value != "" {
+		age, err := parseAge(value)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, MaxFileAge(age))
+	}
+	if value := query.Get("backups"); value != "" {
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid backup count %q: %s", value, err)
+		}
+		options = append(options, MaxBackups(count))
+	}
+	if value := query.Get("compress"); value != "" {
+		compress, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid compress value %q: %s", value, err)
+		}
+		options = append(options, CompressBackups(compress))
+	}
+
+	if len(options) == 0 {
+		return OpenReopenableFileMode(path, mode)
+	}
+	return NewRotatingWriter(path, append(options, RotateFileMode(mode))...)
+}
+
+// ParseSize parses a size such as "100MB", "512k" or "1GiB" into bytes.
+// Suffixes are powers of 1024.
+func ParseSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "B"), "I")
+
+	multiplier := int64(1)
+	if value != "" {
+		switch value[len(value)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			value = value[:len(value)-1]
+		}
+	}
+
+	n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+	return n * multiplier, nil
+}
+
+// parseAge parses a duration, additionally allowing a "d" suffix for days
+func parseAge(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid age: %s", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	age, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age: %s", s)
+	}
+	return age, nil
+}
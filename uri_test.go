@@ -0,0 +1,119 @@
+// Copyright (c) 2021 DDN. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package logging_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/whamcloud/logging"
+)
+
+func TestCreateWriterFileURL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtest-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	w, err := logging.CreateWriter("file://" + path + "?mode=0640&rotate=1KB&backups=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.(io.Closer).Close()
+
+	if _, ok := w.(*logging.RotatingWriter); !ok {
+		t.Fatalf("expected *RotatingWriter, got %T", w)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The umask may remove group read, but nothing else should differ
+	if perm := info.Mode().Perm(); perm&^0640 != 0 || perm&0600 != 0600 {
+		t.Fatalf("unexpected file mode %s", info.Mode())
+	}
+}
+
+func TestCreateWriterTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	w, err := logging.CreateWriter("tcp://" + ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.(io.Closer).Close()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	fmt.Fprint(w, "over the wire\n")
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "over the wire\n" {
+		t.Fatalf("unexpected data: %q", buf[:n])
+	}
+}
+
+func TestRegisterScheme(t *testing.T) {
+	var buf bytes.Buffer
+	logging.RegisterScheme("mem", func(u *url.URL) (io.Writer, error) {
+		if u.Host != "buffer" {
+			return nil, fmt.Errorf("unknown buffer %s", u.Host)
+		}
+		return &buf, nil
+	})
+
+	w, err := logging.CreateWriter("multi:mem://buffer,mem://buffer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(w, "twice\n")
+
+	if buf.String() != "twice\ntwice\n" {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+
+	if _, err := logging.CreateWriter("nosuch://thing"); err == nil {
+		t.Fatal("expected error for unregistered scheme")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for input, expected := range map[string]int64{
+		"512":   512,
+		"100MB": 100 << 20,
+		"4k":    4 << 10,
+		"1GiB":  1 << 30,
+	} {
+		size, err := logging.ParseSize(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != expected {
+			t.Fatalf("%s: expected %d, got %d", input, expected, size)
+		}
+	}
+}